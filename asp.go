@@ -16,7 +16,12 @@ import (
 	"context"
 	"errors"
 	"log" // REVIEW: maybe update to log/slog, go 1.21?
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/cobra"
@@ -58,6 +63,57 @@ type Asp[T Config] interface {
 	// instance of [Asp] was attached to the command, in case additional Viper
 	// customization is needed.
 	Viper() *viper.Viper
+
+	// Provenance reports where each setting's value comes from: the flag,
+	// environment variable, config file, or default that won, and any other
+	// candidate values it overrode.
+	Provenance() ([]Provenance, error)
+
+	// ConfigFiles returns the config files that were read by the most recent
+	// load, in merge order (so later files override earlier ones).  Without
+	// [WithLayeredConfig], there's never more than one.
+	ConfigFiles() []string
+
+	// Profiles returns the names of the profile sections in the config file
+	// (see [WithProfiles]), in order.
+	Profiles() ([]string, error)
+
+	// Current returns the most recently loaded config snapshot, without
+	// reading anything, so it's cheap and safe to call from any goroutine
+	// (like on every request).  The snapshot is shared, so it must be treated
+	// as read-only.  The snapshot is only refreshed by [Asp.Reload] or
+	// [Asp.Watch]; until one of them succeeds, Current returns nil.
+	Current() *T
+
+	// Reload loads the config (through [Asp.Config]) and, if it loads
+	// cleanly, publishes it as the [Asp.Current] snapshot and calls the
+	// [Asp.OnChange] callbacks if it changed.  If it fails, the previous
+	// snapshot is kept, the [Asp.OnReloadError] callbacks are called, and the
+	// error is returned.
+	Reload() error
+
+	// Watch loads the config, and then watches the config files for changes
+	// until the context is done.  Each time a file changes, the config is
+	// reloaded with [Asp.Reload].  Watch returns [ErrNoConfigFile] if no
+	// config file is in use, or any error from the initial load (which isn't
+	// also reported to the [Asp.OnReloadError] callbacks).
+	Watch(ctx context.Context) error
+
+	// ReloadOnSignal calls [Asp.Reload] whenever the process receives one of
+	// the given signals (by default, SIGHUP), until the context is done.
+	// Reload failures don't stop the process; they're reported to the
+	// [Asp.OnReloadError] callbacks.
+	ReloadOnSignal(ctx context.Context, sigs ...os.Signal)
+
+	// OnChange adds a callback that's called with the previous and new
+	// configs whenever a reload results in a different config.  Callbacks are
+	// called one at a time, in order, and must not block for long (or call
+	// [Asp.Reload], which would deadlock).
+	OnChange(fn func(old, new *T))
+
+	// OnReloadError adds a callback that's called whenever a reload fails;
+	// the previous config remains in effect.
+	OnReloadError(fn func(err error))
 }
 
 // DefaultDecodeHook is the default set of decoders that [Asp.Config] uses. See
@@ -68,7 +124,17 @@ var DefaultDecodeHook = mapstructure.ComposeDecodeHookFunc(
 	decoders.StringToByteSlice(),
 	decoders.StringToMapStringInt(),
 	decoders.StringToMapStringString(),
+	decoders.EmptyStringToIP(),
+	mapstructure.StringToIPHookFunc(),
+	mapstructure.StringToIPNetHookFunc(),
+	mapstructure.StringToNetIPAddrHookFunc(),
+	mapstructure.StringToNetIPPrefixHookFunc(),
+	mapstructure.StringToNetIPAddrPortHookFunc(),
+	decoders.StringToURL(),
 	decoders.StringToSlice(","),
+	decoders.NumericRange(),
+	decoders.StringToFlagValue(),
+	decoders.StringToTextUnmarshaler(),
 )
 
 // Attach adds to a [cobra.Command] the command-line arguments, and environment
@@ -111,8 +177,18 @@ func AttachInstance[T Config](cmd *cobra.Command, configDefaults T, options ...O
 		}
 	}
 
+	// The XDG locations replace the default search paths, unless they're
+	// given explicitly.
+	if a.configPaths == nil && !a.xdgConfig {
+		a.configPaths = defaultConfigPaths
+	}
+
 	if a.withConfigFlag {
-		cmd.PersistentFlags().StringVar(&a.cfgFile, "config", "", "configuration file to load")
+		if a.layered {
+			cmd.PersistentFlags().StringArrayVar(&a.cfgFiles, "config", nil, "configuration file to load (may be repeated; later files override earlier ones)")
+		} else {
+			cmd.PersistentFlags().StringVar(&a.cfgFile, "config", "", "configuration file to load")
+		}
 	}
 
 	err = a.processStruct(configDefaults)
@@ -120,13 +196,11 @@ func AttachInstance[T Config](cmd *cobra.Command, configDefaults T, options ...O
 		return nil, err
 	}
 
-	if a.defaultCfgName != "" {
-		vip.SetConfigName(a.defaultCfgName)
-
-		vip.AddConfigPath(".")
-		vip.AddConfigPath("$HOME/.config")
-		vip.AddConfigPath("$HOME")
-		vip.AddConfigPath("/etc")
+	if a.profiles {
+		if cmd.PersistentFlags().Lookup("profile") != nil {
+			return nil, errors.New("the --profile flag is already in use by a config field")
+		}
+		cmd.PersistentFlags().StringVar(&a.profile, "profile", "", "configuration profile to use (env: "+a.profileEnv()+")")
 	}
 
 	// In addition to setting up flags and config, also seed a pre-run on the
@@ -139,6 +213,16 @@ func AttachInstance[T Config](cmd *cobra.Command, configDefaults T, options ...O
 	prevPreRunE := cmd.PersistentPreRunE
 	prevPreRun := cmd.PersistentPreRun
 
+	// The instance is also stashed in the command's context right away, so
+	// that a command tree can be inspected (see [Reference]) without running
+	// it.
+	baseCtx := cmd.Context()
+	if baseCtx == nil {
+		baseCtx = context.Background()
+	}
+	attachedCtx := context.WithValue(baseCtx, ContextKey, a)
+	cmd.SetContext(attachedCtx)
+
 	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		contextCmd := cmd
 		for contextCmd != nil && contextCmd != attachedCmd {
@@ -149,8 +233,16 @@ func AttachInstance[T Config](cmd *cobra.Command, configDefaults T, options ...O
 			return errors.New("unexpected: attached command not in command chain")
 		}
 
-		// get or create the context
+		// get or create the context; since cobra only passes the execution
+		// context to a command that doesn't already have one, the context
+		// stashed above gives way to the root's
 		ctx := contextCmd.Context()
+		if ctx == attachedCtx {
+			ctx = baseCtx
+			if rootCtx := cmd.Root().Context(); rootCtx != nil && rootCtx != attachedCtx {
+				ctx = rootCtx
+			}
+		}
 		if ctx == nil {
 			ctx = context.Background()
 		}
@@ -174,6 +266,21 @@ func AttachInstance[T Config](cmd *cobra.Command, configDefaults T, options ...O
 	return a, nil
 }
 
+// attachedInstance returns the instance attached to the command itself (rather
+// than to one of its ancestors), if there is one.
+func attachedInstance(cmd *cobra.Command) (instance, bool) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		return nil, false
+	}
+
+	inst, ok := ctx.Value(ContextKey).(instance)
+	if !ok || inst.base().cmd != cmd {
+		return nil, false
+	}
+	return inst, true
+}
+
 // Get retrieves the asp instance from the [cobra.Command]'s context and gets
 // the current configuration from flags, environment variables, and config
 // files.
@@ -200,7 +307,52 @@ type aspBase struct {
 	cmd     *cobra.Command
 	cfgFile string
 
+	// layered config reads the default config file from *every* search path,
+	// and every (repeated) `--config` file, and merges them.
+	layered  bool
+	cfgFiles []string
+
+	// configPaths are the directories searched for the default config file,
+	// and xdgConfig adds the XDG locations.
+	configPaths []string
+	xdgConfig   bool
+
+	// profiles splits the config file into a base section and profile
+	// sections, selected with `--profile` (profile) or `APP_PROFILE`;
+	// profileSections are the sections from the most recent load.
+	profiles        bool
+	profile         string
+	profileSections map[string]map[string]any
+
+	// dropInDir is the directory (relative to each config file) of fragments
+	// to merge over the config file.
+	dropInDir string
+
+	// filesRead are the config files read by the most recent load, in merge
+	// order, and dropInDirsRead are the drop-in directories it checked.
+	filesRead      []string
+	dropInDirsRead []string
+
+	// vipMu guards the viper instance, which isn't safe for concurrent use
+	// (and which reading the config changes).
+	vipMu sync.Mutex
+
 	baseType reflect.Type
+
+	// optionalKeys are the (lower-cased) viper keys for pointer fields, which
+	// should remain nil unless some source supplies a value.
+	optionalKeys []string
+
+	// collections are the slice-of-structs fields, whose flags and
+	// environment variables are handled outside of viper.
+	collections []*collection
+
+	// fields is the registry of every leaf setting (and collection).
+	fields []*field
+
+	strictConfig   bool
+	strictEnv      bool
+	warningHandler func(err error)
 }
 
 // I'm using the generic T to "seed" the type at the time that Attach() is
@@ -209,6 +361,7 @@ type aspBase struct {
 // explicitly provide the type in the .Config() call,
 type asp[T Config] struct {
 	aspBase
+	watchState[T]
 }
 
 func (a *aspBase) Command() *cobra.Command {
@@ -220,6 +373,65 @@ func (a *aspBase) Viper() *viper.Viper {
 }
 
 func (a *asp[T]) Config() (*T, error) {
+	a.vipMu.Lock()
+	defer a.vipMu.Unlock()
+
+	val := reflect.New(a.baseType)
+	// log.Printf("created config: %+v", val.Interface())
+	cfg := val.Interface().(*T)
+	// log.Printf("viper settings: %#v", a.vip.AllSettings())
+	err := a.readConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.checkStrictConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.checkStrictEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.checkRequired()
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		DecodeHook:       a.decodeHook,
+		Result:           cfg,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = decoder.Decode(a.settings())
+
+	if err != nil {
+		// TODO (?): create wrapping error?
+		log.Printf("unmarshal config error: %+v", err)
+		return nil, err
+	}
+
+	err = a.validate(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// log.Printf("returning merged config: %+v", cfg)
+	return cfg, nil
+}
+
+// readConfig reads the config file (if any) into viper.
+func (a *aspBase) readConfig() error {
+	if a.layered || a.dropInDir != "" || a.profiles {
+		return a.readMergedConfig()
+	}
+
 	// Before reading the config, check to see if there was a `--config` option
 	// that specifies a particular config file!
 	expectCfgFile := false
@@ -230,12 +442,28 @@ func (a *asp[T]) Config() (*T, error) {
 		// a.vip.AddConfigPath(".")
 		a.vip.SetConfigFile(a.cfgFile)
 		expectCfgFile = true
+	} else {
+		// We search for the default config file ourselves (rather than
+		// letting viper do it), since the XDG locations don't use the
+		// default config name as the file name.
+		file := a.findDefaultConfigFile()
+		if file == "" {
+			// Clear anything from a config file that's since gone away.
+			a.vip.SetConfigType("yaml")
+			err := a.vip.ReadConfig(strings.NewReader(""))
+			if err != nil {
+				return err
+			}
+			a.filesRead = nil
+			return nil
+		}
+		a.vip.SetConfigFile(file)
 	}
 
-	val := reflect.New(a.baseType)
-	// log.Printf("created config: %+v", val.Interface())
-	cfg := val.Interface().(*T)
-	// log.Printf("viper settings: %#v", a.vip.AllSettings())
+	// Clearing the config (above) leaves the config type set, so it has to be
+	// set from the file's extension every time.
+	a.vip.SetConfigType(strings.TrimPrefix(filepath.Ext(a.vip.ConfigFileUsed()), "."))
+
 	err := a.vip.ReadInConfig()
 	if err != nil {
 		switch err.(type) {
@@ -244,24 +472,73 @@ func (a *asp[T]) Config() (*T, error) {
 			if expectCfgFile {
 				// TODO: create wrapping error?
 				log.Printf("specified config file %q not found", a.cfgFile)
-				return nil, err
+				return err
 			}
 			// log.Printf("no config file found... perhaps there are environment variables")
 		default:
 			// TODO (?): create wrapping error?
 			log.Printf("read config error: (%T) %s", err, err.Error())
-			return nil, err
+			return err
 		}
 	}
 
-	err = a.vip.Unmarshal(cfg, viper.DecodeHook(a.decodeHook))
+	a.filesRead = nil
+	if file := a.vip.ConfigFileUsed(); file != "" && err == nil {
+		a.filesRead = []string{file}
+	}
 
-	if err != nil {
-		// TODO (?): create wrapping error?
-		log.Printf("unmarshal config error: %+v", err)
-		return nil, err
+	return nil
+}
+
+func (a *aspBase) ConfigFiles() []string {
+	a.vipMu.Lock()
+	defer a.vipMu.Unlock()
+	return slices.Clone(a.filesRead)
+}
+
+// settings is the equivalent of [viper.Viper.AllSettings] (which is what
+// [viper.Viper.Unmarshal] uses), except that keys for optional (pointer)
+// fields are omitted if no source has actually set them. Viper would otherwise
+// fall back to the bound flag's default value, and we'd never see a nil
+// pointer.  Collections (slices and maps of structs) are also overlaid from
+// their flag or environment variables, which viper knows nothing about.
+func (a *aspBase) settings() map[string]any {
+	m := map[string]any{}
+
+	for _, key := range a.vip.AllKeys() {
+		if a.isOptionalKey(key) && !a.vip.IsSet(key) {
+			continue
+		}
+
+		val := a.vip.Get(key)
+		if val == nil {
+			continue
+		}
+
+		// walk/create the nested maps, just like viper does
+		setPath(m, key, val)
 	}
 
-	// log.Printf("returning merged config: %+v", cfg)
-	return cfg, nil
+	// Flags take precedence over environment variables, which take precedence
+	// over the config file and defaults, just like viper.
+	for _, c := range a.collections {
+		if c.flag.changed {
+			setPath(m, c.key(), c.flag.value())
+		} else if fromEnv := c.fromEnv(); fromEnv != nil {
+			setPath(m, c.key(), fromEnv)
+		}
+	}
+
+	return m
+}
+
+// isOptionalKey returns whether the key is (or is inside of) an optional
+// field.
+func (a *aspBase) isOptionalKey(key string) bool {
+	for _, optional := range a.optionalKeys {
+		if key == optional || strings.HasPrefix(key, optional+".") {
+			return true
+		}
+	}
+	return false
 }
@@ -3,6 +3,9 @@ package asp
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"net"
+	"net/netip"
 	"testing"
 	"time"
 
@@ -129,7 +132,7 @@ func TestAttachWithUnsupportedConfig(t *testing.T) {
 	cmd := &cobra.Command{}
 
 	badConfig := struct {
-		BadMember *int // we don't support pointer members!
+		BadMember chan int // we don't support channel members!
 	}{}
 
 	err := Attach(cmd, badConfig)
@@ -275,3 +278,488 @@ func TestAttachWithExecuteContext(t *testing.T) {
 
 	assert.True(t, ranCmd)
 }
+
+// An attached subcommand still gets the context given to the root command.
+func TestAttachSubcommandWithExecuteContext(t *testing.T) {
+	ranCmd := false
+
+	root := &cobra.Command{Use: "root"}
+	sub := &cobra.Command{
+		Use: "sub",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := Get[aspTestConfig](cmd)
+			assert.NoError(t, err)
+			assert.NotNil(t, cfg)
+
+			assert.Equal(t, "TEST", cmd.Context().Value("TEST"))
+			ranCmd = true
+		},
+	}
+	root.AddCommand(sub)
+
+	err := Attach(sub, defaultConfig)
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), "TEST", "TEST")
+	root.SetArgs([]string{"sub"})
+	err = root.ExecuteContext(ctx)
+	assert.NoError(t, err)
+
+	assert.True(t, ranCmd)
+}
+
+type optionalTestConfig struct {
+	Int      *int
+	String   *string
+	Bool     *bool
+	Duration *time.Duration
+	Nested   *struct {
+		Inner string
+	}
+}
+
+func TestConfigOptionalFields(t *testing.T) {
+	cmd := &cobra.Command{}
+
+	a, err := AttachInstance(cmd, optionalTestConfig{})
+	assert.NoError(t, err)
+
+	// nothing set, so everything should be nil
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Nil(t, cfg.Int)
+	assert.Nil(t, cfg.String)
+	assert.Nil(t, cfg.Bool)
+	assert.Nil(t, cfg.Duration)
+	assert.Nil(t, cfg.Nested)
+
+	// zero values are still "set"...
+	t.Setenv("APP_STRING", "from-env")
+	t.Setenv("APP_NESTED_INNER", "nested")
+	err = cmd.ParseFlags([]string{"--int", "0", "--bool=false", "--duration", "5s"})
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg.Int) {
+		assert.Equal(t, 0, *cfg.Int)
+	}
+	if assert.NotNil(t, cfg.String) {
+		assert.Equal(t, "from-env", *cfg.String)
+	}
+	if assert.NotNil(t, cfg.Bool) {
+		assert.Equal(t, false, *cfg.Bool)
+	}
+	if assert.NotNil(t, cfg.Duration) {
+		assert.Equal(t, 5*time.Second, *cfg.Duration)
+	}
+	if assert.NotNil(t, cfg.Nested) {
+		assert.Equal(t, "nested", cfg.Nested.Inner)
+	}
+}
+
+type aspOptionalFileConfig struct {
+	Int     *int
+	Missing *string
+}
+
+func TestConfigOptionalFieldsFromFile(t *testing.T) {
+	cmd := &cobra.Command{}
+
+	a, err := AttachInstance(cmd, aspOptionalFileConfig{})
+	assert.NoError(t, err)
+
+	aspActual := a.(*asp[aspOptionalFileConfig])
+	aspActual.cfgFile = "asp_test_config.yaml"
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg.Int) {
+		assert.Equal(t, 5, *cfg.Int)
+	}
+	assert.Nil(t, cfg.Missing)
+}
+
+func TestConfigOptionalFieldsWithDefaults(t *testing.T) {
+	cmd := &cobra.Command{}
+
+	five := 5
+	a, err := AttachInstance(cmd, optionalTestConfig{Int: &five})
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	if assert.NotNil(t, cfg.Int) {
+		assert.Equal(t, 5, *cfg.Int)
+	}
+	assert.Nil(t, cfg.String)
+
+	// make sure we didn't alias the default!
+	*cfg.Int = 6
+	assert.Equal(t, 5, five)
+}
+
+type sizedTestConfig struct {
+	Int8        int8
+	Uint16      uint16
+	Float32     float32
+	Float64     float64
+	Int64Slice  []int64
+	Uint16Slice []uint16
+}
+
+func TestConfigSizedNumbers(t *testing.T) {
+	cmd := &cobra.Command{}
+
+	a, err := AttachInstance(cmd, sizedTestConfig{})
+	assert.NoError(t, err)
+	aspActual := a.(*asp[sizedTestConfig])
+
+	aspActual.cfgFile = "asp_test_config_sized.yaml"
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, int8(127), cfg.Int8)
+	assert.Equal(t, uint16(65535), cfg.Uint16)
+	assert.Equal(t, float32(1.5), cfg.Float32)
+	assert.Equal(t, 0.25, cfg.Float64)
+	assert.Equal(t, []int64{1, -2, 3}, cfg.Int64Slice)
+
+	aspActual.cfgFile = "asp_test_config_sized_bad.yaml"
+	_, err = a.Config()
+	assert.ErrorContains(t, err, "out of range")
+
+	// viper remembers the config file, so we need a new instance...
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, sizedTestConfig{})
+	assert.NoError(t, err)
+
+	t.Setenv("APP_UINT16", "65536")
+	_, err = a.Config()
+	assert.ErrorContains(t, err, "out of range")
+
+	t.Setenv("APP_UINT16", "42")
+	t.Setenv("APP_UINT16SLICE", "1,2,3")
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(42), cfg.Uint16)
+	assert.Equal(t, []uint16{1, 2, 3}, cfg.Uint16Slice)
+
+	err = cmd.ParseFlags([]string{"--int-8", "-128", "--int-64-slice", "4,5", "--uint-16-slice", "6", "--float-64", "2.5"})
+	assert.NoError(t, err)
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, int8(-128), cfg.Int8)
+	assert.Equal(t, []int64{4, 5}, cfg.Int64Slice)
+	assert.Equal(t, []uint16{6}, cfg.Uint16Slice)
+	assert.Equal(t, 2.5, cfg.Float64)
+
+	err = cmd.ParseFlags([]string{"--int-8", "128"})
+	assert.Error(t, err)
+}
+
+func TestConfigCustomTypes(t *testing.T) {
+	cmd := &cobra.Command{}
+
+	a, err := AttachInstance(cmd, processCustomTestConfig{Level: slog.LevelWarn})
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, cfg.Level)
+	assert.Nil(t, cfg.Optional)
+
+	t.Setenv("APP_LEVEL", "debug")
+	t.Setenv("APP_REGION", "us")
+	t.Setenv("APP_UPSTREAM", "example.com:8080")
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, cfg.Level)
+	assert.Equal(t, regionCode("US"), cfg.Region)
+	assert.Equal(t, hostPort{"example.com", 8080}, cfg.Upstream)
+
+	err = cmd.ParseFlags([]string{"--level", "error", "--region", "eu", "--upstream", "[::1]:443", "--optional", "ca"})
+	assert.NoError(t, err)
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelError, cfg.Level)
+	assert.Equal(t, regionCode("EU"), cfg.Region)
+	assert.Equal(t, hostPort{"::1", 443}, cfg.Upstream)
+	if assert.NotNil(t, cfg.Optional) {
+		assert.Equal(t, regionCode("CA"), *cfg.Optional)
+	}
+
+	err = cmd.ParseFlags([]string{"--region", "bogus"})
+	assert.ErrorContains(t, err, "invalid region")
+
+	// errors from the environment name the field
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, processCustomTestConfig{})
+	assert.NoError(t, err)
+	t.Setenv("APP_REGION", "bogus")
+	_, err = a.Config()
+	assert.ErrorContains(t, err, "Region")
+}
+
+func TestConfigNetworkTypes(t *testing.T) {
+	cmd := &cobra.Command{}
+
+	a, err := AttachInstance(cmd, processNetworkTestConfig{})
+	assert.NoError(t, err)
+	aspActual := a.(*asp[processNetworkTestConfig])
+
+	aspActual.cfgFile = "asp_test_config_network.yaml"
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, net.ParseIP("10.0.0.1"), cfg.IP)
+	assert.Equal(t, "10.0.0.0/8", cfg.IPNet.String())
+	assert.Equal(t, netip.MustParseAddr("::1"), cfg.Addr)
+	assert.Equal(t, netip.MustParsePrefix("192.168.0.0/16"), cfg.Prefix)
+	assert.Equal(t, netip.MustParseAddrPort("127.0.0.1:8080"), cfg.AddrPort)
+	assert.Equal(t, "https://example.com/api", cfg.URL.String())
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}, cfg.Prefixes)
+	assert.Equal(t, "http://two", cfg.URLs[1].String())
+	assert.Nil(t, cfg.Upstream)
+
+	// environment and flags
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, processNetworkTestConfig{})
+	assert.NoError(t, err)
+
+	t.Setenv("APP_IPS", "1.2.3.4,::1")
+	t.Setenv("APP_IPNETS", "10.0.0.0/8,fd00::/8")
+	t.Setenv("APP_ADDRS", "1.2.3.4")
+	t.Setenv("APP_ADDRPORTS", "1.2.3.4:80,[::1]:443")
+	t.Setenv("APP_UPSTREAM", "http://upstream:8080")
+	err = cmd.ParseFlags([]string{"--prefixes", "10.0.0.0/8,172.16.0.0/12", "--ur-ls", "http://a", "--ip", "::1"})
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, []net.IP{net.ParseIP("1.2.3.4"), net.ParseIP("::1")}, cfg.IPs)
+	if assert.Len(t, cfg.IPNets, 2) {
+		assert.Equal(t, "fd00::/8", cfg.IPNets[1].String())
+	}
+	assert.Equal(t, []netip.Addr{netip.MustParseAddr("1.2.3.4")}, cfg.Addrs)
+	assert.Equal(t, []netip.AddrPort{
+		netip.MustParseAddrPort("1.2.3.4:80"),
+		netip.MustParseAddrPort("[::1]:443"),
+	}, cfg.AddrPorts)
+	if assert.NotNil(t, cfg.Upstream) {
+		assert.Equal(t, "upstream:8080", cfg.Upstream.Host)
+	}
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+	}, cfg.Prefixes)
+	assert.Equal(t, "http://a", cfg.URLs[0].String())
+	assert.Equal(t, net.ParseIP("::1"), cfg.IP)
+
+	// parse errors name the field
+	cases := map[string]string{
+		"APP_ADDR":     "Addr",
+		"APP_PREFIXES": "Prefixes",
+		"APP_IP":       "IP",
+		"APP_URL":      "URL",
+	}
+	for env, field := range cases {
+		t.Run(env, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			a, err := AttachInstance(cmd, processNetworkTestConfig{})
+			assert.NoError(t, err)
+
+			t.Setenv(env, "://bogus")
+			_, err = a.Config()
+			assert.ErrorContains(t, err, "'"+field)
+		})
+	}
+}
+
+func TestConfigCollections(t *testing.T) {
+	defaults := processCollectionTestConfig{
+		Upstreams: []upstream{{Host: "default"}},
+	}
+
+	// defaults only
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, defaults)
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, []upstream{{Host: "default"}}, cfg.Upstreams)
+	assert.Empty(t, cfg.Nested.Backends)
+
+	// config file
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, defaults)
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", "asp_test_config_collection.yaml"})
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	if assert.Len(t, cfg.Upstreams, 2) {
+		assert.Equal(t, "one.example.com", cfg.Upstreams[0].Host)
+		assert.Equal(t, 2, cfg.Upstreams[1].Weight)
+		assert.True(t, cfg.Upstreams[1].TLS.Enabled)
+	}
+	assert.Equal(t, []Nested{{Dummy: 7}}, cfg.Nested.Backends)
+
+	// indexed environment variables override the file
+	t.Setenv("APP_UPSTREAMS_1_HOST", "env-one")
+	t.Setenv("APP_UPSTREAMS_0_HOST", "env-zero")
+	t.Setenv("APP_UPSTREAMS_0_TLS_ENABLED", "true")
+	t.Setenv("APP_UPSTREAMS_X_HOST", "ignored")
+	t.Setenv("APP_NESTED_BACKENDS_0_DUMMY", "3")
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, []upstream{
+		{Host: "env-zero", TLS: struct{ Enabled bool }{true}},
+		{Host: "env-one"},
+	}, cfg.Upstreams)
+	assert.Equal(t, []Nested{{Dummy: 3}}, cfg.Nested.Backends)
+
+	// repeated flags override the environment
+	err = cmd.ParseFlags([]string{"--upstreams", "host=flag-zero,weight=5", "--upstreams", "host=flag-one"})
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, []upstream{
+		{Host: "flag-zero", Weight: 5},
+		{Host: "flag-one"},
+	}, cfg.Upstreams)
+
+	// bad values name the field
+	t.Setenv("APP_NESTED_BACKENDS_0_DUMMY", "bogus")
+	_, err = a.Config()
+	assert.ErrorContains(t, err, "Dummy")
+}
+
+func TestConfigKeyedCollections(t *testing.T) {
+	defaults := processKeyedTestConfig{
+		Databases: map[string]database{"local": {Host: "localhost"}},
+	}
+
+	// defaults only
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, defaults)
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, defaults.Databases, cfg.Databases)
+
+	// config file
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, defaults)
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", "asp_test_config_keyed.yaml"})
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]database{
+		"primary": {Host: "db1.example.com", Port: 5432},
+		"replica": {Host: "db2.example.com"},
+	}, cfg.Databases)
+
+	// named environment variables override the file
+	t.Setenv("APP_DATABASES_PRIMARY_HOST", "env-primary")
+	t.Setenv("APP_DATABASES_PRIMARY_PORT", "1234")
+	t.Setenv("APP_DATABASES_MY_REPLICA_PASSWORD", "secret")
+	t.Setenv("APP_DATABASES_HOST", "ignored")
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]database{
+		"primary":    {Host: "env-primary", Port: 1234},
+		"my_replica": {Password: "secret"},
+	}, cfg.Databases)
+
+	// flags override the environment
+	err = cmd.ParseFlags([]string{"--databases", "flag.host=flag-host"})
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]database{"flag": {Host: "flag-host"}}, cfg.Databases)
+}
+
+func TestConfigTagDefaults(t *testing.T) {
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, processTagDefaultsTestConfig{Int: 5})
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, cfg.Int)
+	assert.Equal(t, "hello", cfg.String)
+	assert.Equal(t, 30*time.Second, cfg.Duration)
+	assert.Equal(t, []string{"a", "b"}, cfg.Strings)
+	assert.Equal(t, slog.LevelWarn, cfg.Level)
+	if assert.NotNil(t, cfg.Optional) {
+		assert.Equal(t, 7, *cfg.Optional)
+	}
+	assert.True(t, cfg.Nested.Deep)
+
+	// other sources still take precedence
+	t.Setenv("APP_STRING", "env")
+	err = cmd.ParseFlags([]string{"--duration", "1m"})
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "env", cfg.String)
+	assert.Equal(t, time.Minute, cfg.Duration)
+}
+
+type requiredTestConfig struct {
+	Name     string `asp:",,,,,true"`
+	Port     int    `asp.required:"true"`
+	Database struct {
+		Host string `asp.required:"true"`
+		User string
+	}
+	Backends []Nested `asp.required:"true"`
+}
+
+func TestConfigRequired(t *testing.T) {
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, requiredTestConfig{Port: 80})
+	assert.NoError(t, err)
+
+	// defaults don't count, and *every* missing value is reported
+	_, err = a.Config()
+	assert.ErrorIs(t, err, ErrRequiredMissing)
+
+	var reqErr *RequiredError
+	if assert.ErrorAs(t, err, &reqErr) {
+		assert.Equal(t, []Setting{
+			{Field: "Name", Key: "name", Flag: "name", Env: "APP_NAME"},
+			{Field: "Port", Key: "port", Flag: "port", Env: "APP_PORT"},
+			{Field: "Database.Host", Key: "database.host", Flag: "database-host", Env: "APP_DATABASE_HOST"},
+			{Field: "Backends", Key: "backends", Flag: "backends", Env: "APP_BACKENDS"},
+		}, reqErr.Missing)
+	}
+	assert.ErrorContains(t, err, "Database.Host (--database-host, APP_DATABASE_HOST, database.host)")
+
+	// config file, environment, and flags all count
+	err = cmd.ParseFlags([]string{"--config", "asp_test_config_required.yaml", "--name", "x"})
+	assert.NoError(t, err)
+	t.Setenv("APP_PORT", "8080")
+	t.Setenv("APP_BACKENDS_0_DUMMY", "1")
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "x", cfg.Name)
+	assert.Equal(t, 8080, cfg.Port)
+	assert.Equal(t, "db.example.com", cfg.Database.Host)
+	assert.Equal(t, []Nested{{Dummy: 1}}, cfg.Backends)
+}
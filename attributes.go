@@ -43,24 +43,42 @@ func getAttributes(f reflect.StructField) attrs {
 }
 
 type tagInfo struct {
-	tagName string
-	setter  func(*attrs, string)
+	tagName  string
+	setter   func(*attrs, string)
+	combined bool // whether the attribute is also available in the `asp` tag
 }
 
 // Note that attrTags serves double-duty; it's the list of tags to parse, *and*
-// the order of attribute-specific tags is their order inside the high-level
-// overall tag... so that we can look up the indexed setter by adding 1.
+// the order of the combined attribute-specific tags is their order inside the
+// high-level overall tag.  Only the combined tags are available inside the
+// high-level tag; the rest (like `asp.default`, whose value may well include
+// commas) must always be given explicitly.
 var attrTags []tagInfo
 
+// combinedSetters are the setters for the combined tags, in order.
+var combinedSetters []func(*attrs, string)
+
 func init() {
 	// has to be set in init to avoid circular use inside attrs.setAll()!
 	attrTags = []tagInfo{
-		{"asp", (*attrs).setAll},
-		{"asp.long", (*attrs).setLong},
-		{"asp.short", (*attrs).setShort},
-		{"asp.env", (*attrs).setEnv},
-		{"asp.desc", (*attrs).setDesc},
-		{"asp.sensitive", (*attrs).setSensitive},
+		{"asp", (*attrs).setAll, false},
+		{"asp.long", (*attrs).setLong, true},
+		{"asp.short", (*attrs).setShort, true},
+		{"asp.env", (*attrs).setEnv, true},
+		{"asp.desc", (*attrs).setDesc, true},
+		{"asp.sensitive", (*attrs).setSensitive, true},
+		{"asp.required", (*attrs).setRequired, true},
+		{"asp.default", (*attrs).setDefault, false},
+		{"asp.min", (*attrs).setMin, false},
+		{"asp.max", (*attrs).setMax, false},
+		{"asp.oneof", (*attrs).setOneOf, false},
+		{"asp.pattern", (*attrs).setPattern, false},
+	}
+
+	for _, tag := range attrTags {
+		if tag.combined {
+			combinedSetters = append(combinedSetters, tag.setter)
+		}
 	}
 }
 
@@ -74,6 +92,19 @@ type attrs struct {
 	env       string
 	desc      string // *template* string to allow full name to be substituted in
 	sensitive bool
+	required  bool
+	def       string // *string* form of the default, parsed by the decode hook
+
+	// constraints, checked after loading the config
+	min     string
+	max     string
+	oneOf   string
+	pattern string
+
+	// optional is not parsed from the tags; it's set while processing a nil
+	// pointer field (and inherited by any children) to indicate that no
+	// default value should be registered for it.
+	optional bool
 }
 
 func (a *attrs) setAll(s string) {
@@ -82,12 +113,12 @@ func (a *attrs) setAll(s string) {
 		return
 	}
 
-	parts := strings.SplitN(s, ",", len(attrTags)-1)
+	parts := strings.SplitN(s, ",", len(combinedSetters))
 
 	for i, p := range parts {
 		p = strings.TrimSpace(p)
 		if len(p) > 0 {
-			attrTags[i+1].setter(a, p)
+			combinedSetters[i](a, p)
 		}
 	}
 }
@@ -97,6 +128,12 @@ func (a *attrs) setShort(s string)     { a.short = s }
 func (a *attrs) setEnv(s string)       { a.env = s }
 func (a *attrs) setDesc(s string)      { a.desc = s }
 func (a *attrs) setSensitive(s string) { a.sensitive = (strings.ToLower(s) == "true") }
+func (a *attrs) setRequired(s string)  { a.required = (strings.ToLower(s) == "true") }
+func (a *attrs) setDefault(s string)   { a.def = s }
+func (a *attrs) setMin(s string)       { a.min = s }
+func (a *attrs) setMax(s string)       { a.max = s }
+func (a *attrs) setOneOf(s string)     { a.oneOf = s }
+func (a *attrs) setPattern(s string)   { a.pattern = s }
 
 // combine builds a new attribute set using the aggregation/combination rules
 // for each individual field
@@ -107,7 +144,14 @@ func (a *attrs) join(child attrs) attrs {
 		short:     child.short, // short flags are *never* joined!
 		env:       joinField(a.env, child.env, "_"),
 		desc:      child.desc, // descriptions are *never* joined!
+		def:       child.def,  // neither are defaults!
+		min:       child.min,  // ... or constraints
+		max:       child.max,
+		oneOf:     child.oneOf,
+		pattern:   child.pattern,
 		sensitive: a.sensitive || child.sensitive,
+		required:  child.required, // a required struct doesn't make its fields required
+		optional:  a.optional || child.optional,
 	}
 }
 
@@ -70,6 +70,67 @@ func TestGetAttributes(t *testing.T) {
 	}
 }
 
+func TestGetAttributesDefault(t *testing.T) {
+	t.Parallel()
+
+	// fields: tag, default
+	cases := map[string][2]string{
+		"no tag":        {``, ""},
+		"default only":  {`asp.default:"a,b"`, "a,b"},
+		"all ignored":   {`asp:"r,r,R,R,false,nope"`, ""},
+		"all and other": {`asp:"r,r,R,R,false" asp.default:"42"`, "42"},
+	}
+
+	for k, v := range cases {
+		tag, expected := v[0], v[1]
+
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+
+			f := reflect.StructField{
+				Name: "Ex",
+				Tag:  reflect.StructTag(tag),
+			}
+
+			attrs := getAttributes(f)
+			assert.Equal(t, expected, attrs.def)
+			assert.False(t, attrs.sensitive)
+		})
+	}
+}
+
+func TestGetAttributesRequired(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		tag       string
+		sensitive bool
+		required  bool
+	}{
+		"no tag":         {``, false, false},
+		"required only":  {`asp.required:"true"`, false, true},
+		"required false": {`asp.required:"false"`, false, false},
+		"all required":   {`asp:",,,,,true"`, false, true},
+		"all both":       {`asp:",,,,true,true"`, true, true},
+		"all and other":  {`asp:",,,,,true" asp.required:"false"`, false, false},
+	}
+
+	for k, v := range cases {
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+
+			f := reflect.StructField{
+				Name: "Ex",
+				Tag:  reflect.StructTag(v.tag),
+			}
+
+			attrs := getAttributes(f)
+			assert.Equal(t, v.sensitive, attrs.sensitive)
+			assert.Equal(t, v.required, attrs.required)
+		})
+	}
+}
+
 func TestJoinField(t *testing.T) {
 	t.Parallel()
 
@@ -100,17 +161,26 @@ func TestJoinField(t *testing.T) {
 func TestAttrsJoin(t *testing.T) {
 	t.Parallel()
 
-	attrsNone := attrs{false, "", "", "", "", "", false}
-	attrsAll := attrs{false, "Name", "long", "s", "ENV", "desc", false}
-	attrsSensitive := attrs{false, "", "", "", "", "", true}
+	attrsNone := attrs{}
+	attrsAll := attrs{name: "Name", long: "long", short: "s", env: "ENV", desc: "desc"}
+	attrsSensitive := attrs{sensitive: true}
+	attrsOptional := attrs{optional: true}
+	attrsDefault := attrs{def: "default"}
+	attrsRequired := attrs{required: true}
 
 	cases := map[string][3]attrs{
-		"none none":      {attrsNone, attrsNone, attrs{false, "", "", "", "", "", false}},
-		"none all":       {attrsNone, attrsAll, attrs{false, "Name", "long", "s", "ENV", "desc", false}},
-		"all none":       {attrsAll, attrsNone, attrs{false, "Name", "long", "", "ENV", "", false}},
-		"all all":        {attrsAll, attrsAll, attrs{false, "Name.Name", "long-long", "s", "ENV_ENV", "desc", false}},
+		"none none":      {attrsNone, attrsNone, attrs{}},
+		"none all":       {attrsNone, attrsAll, attrs{name: "Name", long: "long", short: "s", env: "ENV", desc: "desc"}},
+		"all none":       {attrsAll, attrsNone, attrs{name: "Name", long: "long", env: "ENV"}},
+		"all all":        {attrsAll, attrsAll, attrs{name: "Name.Name", long: "long-long", short: "s", env: "ENV_ENV", desc: "desc"}},
 		"none sensitive": {attrsNone, attrsSensitive, attrsSensitive},
 		"sensitive none": {attrsSensitive, attrsNone, attrsSensitive},
+		"none optional":  {attrsNone, attrsOptional, attrsOptional},
+		"optional none":  {attrsOptional, attrsNone, attrsOptional},
+		"none default":   {attrsNone, attrsDefault, attrsDefault},
+		"default none":   {attrsDefault, attrsNone, attrsNone},
+		"none required":  {attrsNone, attrsRequired, attrsRequired},
+		"required none":  {attrsRequired, attrsNone, attrsNone},
 	}
 
 	for k, v := range cases {
@@ -126,6 +196,9 @@ func TestAttrsJoin(t *testing.T) {
 			assert.Equal(t, expected.env, actual.env)
 			assert.Equal(t, expected.desc, actual.desc)
 			assert.Equal(t, expected.sensitive, actual.sensitive)
+			assert.Equal(t, expected.optional, actual.optional)
+			assert.Equal(t, expected.def, actual.def)
+			assert.Equal(t, expected.required, actual.required)
 		})
 	}
 
@@ -0,0 +1,374 @@
+package asp
+
+import (
+	"encoding"
+	"encoding/csv"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Viper has no way to bind a flag or environment variable to a *list* (or
+// map) of structs, so "collection" fields are handled by asp directly: the flag
+// and (indexed or named) environment variables are parsed into generic maps
+// that [aspBase.settings] layers on top of whatever viper provides from the
+// config file and defaults.
+
+// collection holds the bindings for a slice-of-structs or map-of-structs
+// field.
+type collection struct {
+	attrs  attrs // the joined attributes for the field itself
+	leaves []attrs
+	keyed  bool // a map, rather than a slice
+	flag   *collectionValue
+}
+
+// key returns the (lower-cased) viper key for the collection.
+func (c *collection) key() string {
+	return strings.ToLower(c.attrs.name)
+}
+
+// fromEnv looks for the collection's environment variables, returning nil if
+// there aren't any.
+func (c *collection) fromEnv() any {
+	if c.keyed {
+		if entries := c.fromNamedEnv(); len(entries) > 0 {
+			return entries
+		}
+		return nil
+	}
+
+	if items := c.fromIndexedEnv(); len(items) > 0 {
+		return items
+	}
+	return nil
+}
+
+// fromIndexedEnv looks for indexed environment variables, like
+// `APP_UPSTREAMS_0_HOST`, and returns the values as a list of maps, ordered by
+// index.  (Any gaps in the indexes are ignored.)
+func (c *collection) fromIndexedEnv() []any {
+	prefix := c.attrs.env + "_"
+	byIndex := map[int]map[string]any{}
+
+	for _, kv := range os.Environ() {
+		name, val, _ := strings.Cut(kv, "=")
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok || val == "" {
+			continue
+		}
+
+		indexStr, leafEnv, ok := strings.Cut(rest, "_")
+		if !ok {
+			continue
+		}
+
+		index, err := strconv.Atoi(indexStr)
+		if err != nil || index < 0 {
+			continue
+		}
+
+		leaf, ok := c.leafBy(func(l attrs) string { return l.env }, leafEnv)
+		if !ok {
+			continue
+		}
+
+		item, ok := byIndex[index]
+		if !ok {
+			item = map[string]any{}
+			byIndex[index] = item
+		}
+		setPath(item, leaf.name, val)
+	}
+
+	items := make([]any, 0, len(byIndex))
+	for _, index := range slices.Sorted(maps.Keys(byIndex)) {
+		items = append(items, byIndex[index])
+	}
+	return items
+}
+
+// fromNamedEnv looks for named environment variables, like
+// `APP_DATABASES_PRIMARY_HOST`, and returns the values as a map of maps, keyed
+// by the lower-cased name.  Since names may themselves contain underscores, the
+// longest matching field suffix wins.
+func (c *collection) fromNamedEnv() map[string]any {
+	prefix := c.attrs.env + "_"
+	entries := map[string]any{}
+
+	for _, kv := range os.Environ() {
+		name, val, _ := strings.Cut(kv, "=")
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok || val == "" {
+			continue
+		}
+
+		var match *attrs
+		for i, l := range c.leaves {
+			if len(rest) > len(l.env)+1 && strings.HasSuffix(rest, "_"+l.env) &&
+				(match == nil || len(l.env) > len(match.env)) {
+				match = &c.leaves[i]
+			}
+		}
+
+		if match == nil {
+			continue
+		}
+
+		entryName := strings.ToLower(strings.TrimSuffix(rest, "_"+match.env))
+		entry, ok := entries[entryName].(map[string]any)
+		if !ok {
+			entry = map[string]any{}
+			entries[entryName] = entry
+		}
+		setPath(entry, match.name, val)
+	}
+
+	return entries
+}
+
+// matchesEnv returns whether the environment variable is one that
+// [collection.fromEnv] would use, like `APP_UPSTREAMS_0_HOST` or
+// `APP_DATABASES_PRIMARY_HOST`.
+func (c *collection) matchesEnv(name string) bool {
+	rest, ok := strings.CutPrefix(name, c.attrs.env+"_")
+	if !ok {
+		return false
+	}
+
+	if c.keyed {
+		for _, l := range c.leaves {
+			if len(rest) > len(l.env)+1 && strings.HasSuffix(rest, "_"+l.env) {
+				return true
+			}
+		}
+		return false
+	}
+
+	indexStr, leafEnv, ok := strings.Cut(rest, "_")
+	if !ok {
+		return false
+	}
+
+	index, err := strconv.Atoi(indexStr)
+	if err != nil || index < 0 {
+		return false
+	}
+
+	_, ok = c.leafBy(func(l attrs) string { return l.env }, leafEnv)
+	return ok
+}
+
+// envCandidates returns the environment variables that the (possibly
+// misspelled) variable might have meant, by keeping its item index (or name)
+// and trying each of the item fields.  Since names can contain underscores,
+// every possible split is tried.
+func (c *collection) envCandidates(name string) []string {
+	prefix := c.attrs.env + "_"
+	rest, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return nil
+	}
+
+	candidates := []string{}
+	for i, r := range rest {
+		if r != '_' || i == 0 {
+			continue
+		}
+		for _, l := range c.leaves {
+			candidates = append(candidates, prefix+rest[:i]+"_"+l.env)
+		}
+	}
+	return candidates
+}
+
+// leafBy finds the leaf whose (relative) attribute matches the value.
+func (c *collection) leafBy(fn func(attrs) string, val string) (attrs, bool) {
+	for _, l := range c.leaves {
+		if fn(l) == val {
+			return l, true
+		}
+	}
+	return attrs{}, false
+}
+
+// collectionValue is the [pflag.Value] for a collection; each use of the flag
+// provides a single item as comma-separated "key=value" pairs, where the keys
+// are the (relative) long flag names of the item's fields, like
+// `--upstreams host=example.com,weight=2`.  For maps, the keys are prefixed
+// with the entry's name, like `--databases primary.host=example.com`, and
+// multiple uses of the flag for the same name are merged.  There are no
+// indexed flags (like `--upstreams.0.host`), since pflag has to know every
+// flag up front.
+type collectionValue struct {
+	c       *collection
+	values  []string
+	items   []any
+	entries map[string]any
+	changed bool
+}
+
+// Set parses the item and either replaces or appends to the existing value.
+// An empty string clears the collection.
+func (v *collectionValue) Set(s string) error {
+	if !v.changed || s == "" {
+		v.values = []string{}
+		v.items = []any{}
+		v.entries = map[string]any{}
+	}
+	v.changed = true
+
+	if s == "" {
+		return nil
+	}
+
+	pairs, err := csv.NewReader(strings.NewReader(s)).Read()
+	if err != nil {
+		return err
+	}
+
+	item := map[string]any{}
+	for _, pair := range pairs {
+		long, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", pair)
+		}
+
+		target := item
+		if v.c.keyed {
+			var entryName string
+			entryName, long, ok = strings.Cut(long, ".")
+			if !ok || entryName == "" {
+				return fmt.Errorf("expected name.key=value, got %q", pair)
+			}
+
+			entryName = strings.ToLower(entryName)
+			target, ok = v.entries[entryName].(map[string]any)
+			if !ok {
+				target = map[string]any{}
+				v.entries[entryName] = target
+			}
+		}
+
+		leaf, ok := v.c.leafBy(func(l attrs) string { return l.long }, long)
+		if !ok {
+			return fmt.Errorf("unknown key %q (expected one of: %s)", long, strings.Join(mapSlice(v.c.leaves, func(l attrs) string { return l.long }), ", "))
+		}
+
+		setPath(target, leaf.name, val)
+	}
+
+	v.values = append(v.values, s)
+	if !v.c.keyed {
+		v.items = append(v.items, item)
+	}
+	return nil
+}
+
+// Type returns "fields" for the type of the value.
+func (v *collectionValue) Type() string {
+	return "fields"
+}
+
+// String renders the collection as a list of items.
+func (v *collectionValue) String() string {
+	return "[" + strings.Join(v.values, "; ") + "]"
+}
+
+// value returns the parsed list or map.
+func (v *collectionValue) value() any {
+	if v.c.keyed {
+		return v.entries
+	}
+	return v.items
+}
+
+// collectLeaves returns the attributes for all of the (non-struct) fields of
+// the given struct type, relative to parentAttrs.
+func collectLeaves(t reflect.Type, parentAttrs attrs) []attrs {
+	leaves := []attrs{}
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || len(f.Index) > 1 {
+			continue
+		}
+
+		childAttrs := getAttributes(f)
+		if childAttrs.ignored {
+			continue
+		}
+
+		joinedAttrs := parentAttrs.join(childAttrs)
+
+		fieldType := f.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if !isNestedStruct(fieldType) {
+			leaves = append(leaves, joinedAttrs)
+			continue
+		}
+
+		recursiveAttrs := joinedAttrs
+		if f.Anonymous {
+			recursiveAttrs = parentAttrs
+		}
+		leaves = append(leaves, collectLeaves(fieldType, recursiveAttrs)...)
+	}
+
+	return leaves
+}
+
+// isCollection returns whether the type is a slice of structs, or a map of
+// structs with string keys.
+func isCollection(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice:
+		return isNestedStruct(t.Elem())
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && isNestedStruct(t.Elem())
+	}
+	return false
+}
+
+// isNestedStruct returns whether the type is a struct that asp recurses into,
+// as opposed to a struct-based value type like [time.Time] or [netip.Addr].
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	switch t {
+	case reflect.TypeOf(net.IPNet{}), reflect.TypeOf(url.URL{}):
+		return false
+	}
+
+	ptr := reflect.PointerTo(t)
+	return !ptr.Implements(reflect.TypeOf((*pflag.Value)(nil)).Elem()) &&
+		!ptr.Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
+
+// setPath sets a value in nested maps, using the (case-insensitive) dotted
+// name, creating intermediate maps as needed.
+func setPath(m map[string]any, name string, val any) {
+	path := strings.Split(strings.ToLower(name), ".")
+	parent := m
+	for _, p := range path[:len(path)-1] {
+		child, ok := parent[p].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			parent[p] = child
+		}
+		parent = child
+	}
+	parent[path[len(path)-1]] = val
+}
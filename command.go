@@ -0,0 +1,375 @@
+package asp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/cobra"
+)
+
+// ErrNotAttached indicates that a command (or one of its ancestors) was
+// expected to have an [Attach]ed config, but does not.
+var ErrNotAttached = errors.New("no asp config is attached to the command")
+
+// instance is the non-generic view of an [Asp] instance, so that helpers like
+// [AddConfigCommand] don't need to know the config type.
+type instance interface {
+	configAny() (any, error)
+	base() *aspBase
+}
+
+func (a *asp[T]) configAny() (any, error) {
+	return a.Config()
+}
+
+func (a *aspBase) base() *aspBase {
+	return a
+}
+
+// AddConfigCommand adds a `config` subcommand to a command that has an
+// [Attach]ed config, with its own subcommands:
+//
+//   - `config show` prints the effective configuration (with sensitive values
+//     redacted) as YAML, JSON, or TOML
+//   - `config explain` prints where each setting's value comes from (see
+//     [Asp.Provenance])
+//   - `config init [file]` writes a starter config file from the defaults,
+//     with the field descriptions as comments
+//   - `config validate` checks for required values and validates the
+//     configuration
+//   - `config env` lists every environment variable
+//   - `config schema` prints the JSON Schema for the config file (see
+//     [JSONSchema])
+//   - `config profiles` lists the profiles in the config file (see
+//     [WithProfiles])
+//
+// Because the subcommands are children of the attached command, all of its
+// flags are available to them (like `app config show --port 9000`).  The
+// `config` command is returned in case further customization is needed.
+func AddConfigCommand(cmd *cobra.Command) *cobra.Command {
+	attachedCmd := cmd
+
+	// The instance is stashed in the attached command's context by its
+	// persistent pre-run, which has happened by the time any of our
+	// subcommands run.
+	getInstance := func() (instance, error) {
+		ctx := attachedCmd.Context()
+		if ctx == nil {
+			return nil, ErrNotAttached
+		}
+		inst, ok := ctx.Value(ContextKey).(instance)
+		if !ok {
+			return nil, ErrNotAttached
+		}
+		return inst, nil
+	}
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show, explain, and check the configuration",
+	}
+
+	showCmd := &cobra.Command{
+		Use:          "show",
+		Short:        "Print the effective configuration (with sensitive values redacted)",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inst, err := getInstance()
+			if err != nil {
+				return err
+			}
+
+			cfg, err := inst.configAny()
+			if err != nil {
+				return err
+			}
+
+			format, _ := cmd.Flags().GetString("format")
+			b, err := serializeConfigFile(cfg, format, ConfigFileOptions{
+				Sensitive: SensitiveRedact,
+				EnvPrefix: inst.base().envPrefix,
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = cmd.OutOrStdout().Write(b)
+			return err
+		},
+	}
+	showCmd.Flags().String("format", "yaml", "output format (yaml, json, or toml)")
+	configCmd.AddCommand(showCmd)
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:          "explain",
+		Short:        "Print where each configuration value comes from",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inst, err := getInstance()
+			if err != nil {
+				return err
+			}
+
+			provenance, err := inst.base().Provenance()
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			for _, p := range provenance {
+				if p.Winner == nil {
+					fmt.Fprintf(out, "%s: (not set)\n", p.Key)
+					continue
+				}
+
+				fmt.Fprintf(out, "%s: %s\n", p.Key, p.Winner)
+				for _, c := range p.Overridden {
+					fmt.Fprintf(out, "    overrides %s\n", c)
+				}
+			}
+
+			return nil
+		},
+	})
+
+	initCmd := &cobra.Command{
+		Use:          "init [file]",
+		Short:        "Write a starter config file from the defaults (to stdout if no file is given)",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inst, err := getInstance()
+			if err != nil {
+				return err
+			}
+
+			defaults, err := inst.base().defaults()
+			if err != nil {
+				return err
+			}
+
+			// The format comes from the file extension, unless given
+			// explicitly.
+			format, _ := cmd.Flags().GetString("format")
+			if format == "" && len(args) > 0 {
+				format = filepath.Ext(args[0])
+			}
+			if format == "" {
+				format = "yaml"
+			}
+
+			b, err := serializeConfigFile(defaults, format, ConfigFileOptions{
+				Sensitive: SensitiveInclude,
+				Comments:  true,
+				EnvPrefix: inst.base().envPrefix,
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				_, err = cmd.OutOrStdout().Write(b)
+				return err
+			}
+
+			force, _ := cmd.Flags().GetBool("force")
+			flag := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+			if force {
+				flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			}
+
+			f, err := os.OpenFile(args[0], flag, 0o600)
+			if err != nil {
+				return err
+			}
+			_, err = f.Write(b)
+			if err != nil {
+				f.Close()
+				return err
+			}
+
+			// A failed close can mean the data never made it to disk.
+			err = f.Close()
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "wrote %s\n", args[0])
+			return nil
+		},
+	}
+	initCmd.Flags().Bool("force", false, "overwrite the file if it already exists")
+	initCmd.Flags().String("format", "", "file format (yaml, json, or toml; by default, from the file extension)")
+	configCmd.AddCommand(initCmd)
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:          "validate",
+		Short:        "Check for required values and validate the configuration",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inst, err := getInstance()
+			if err != nil {
+				return err
+			}
+
+			_, err = inst.configAny()
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "configuration is valid")
+			return nil
+		},
+	})
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:          "env",
+		Short:        "List every environment variable",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inst, err := getInstance()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ENV\tFLAG\tKEY")
+			for _, f := range inst.base().fields {
+				for _, s := range f.envSettings() {
+					fmt.Fprintf(w, "%s\t--%s\t%s\n", s.Env, s.Flag, s.Key)
+				}
+			}
+			if a := inst.base(); a.profiles {
+				fmt.Fprintf(w, "%s\t--profile\t-\n", a.profileEnv())
+			}
+			return w.Flush()
+		},
+	})
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:          "schema",
+		Short:        "Print the JSON Schema for the config file",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inst, err := getInstance()
+			if err != nil {
+				return err
+			}
+
+			a := inst.base()
+			defaults, err := a.defaults()
+			if err != nil {
+				return err
+			}
+
+			b, err := a.jsonSchema(defaults, SchemaOptions{
+				Title:     attachedCmd.Name(),
+				EnvPrefix: a.envPrefix,
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = cmd.OutOrStdout().Write(b)
+			return err
+		},
+	})
+
+	configCmd.AddCommand(&cobra.Command{
+		Use:          "profiles",
+		Short:        "List the profiles in the config file",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inst, err := getInstance()
+			if err != nil {
+				return err
+			}
+
+			profiles, err := inst.base().Profiles()
+			if err != nil {
+				return err
+			}
+
+			for _, profile := range profiles {
+				fmt.Fprintln(cmd.OutOrStdout(), profile)
+			}
+			return nil
+		},
+	})
+
+	cmd.AddCommand(configCmd)
+	return configCmd
+}
+
+// defaultsMap returns the default values for every setting, in the
+// nested-map form of a config file.  Optional fields without a default are
+// omitted.
+func (a *aspBase) defaultsMap() map[string]any {
+	m := map[string]any{}
+
+	for _, f := range a.fields {
+		if !f.hasDef {
+			continue
+		}
+		setPath(m, strings.ToLower(f.attrs.name), plainValue(reflect.ValueOf(f.defVal), false, f.attrs))
+	}
+
+	return m
+}
+
+// defaults returns a config (a pointer to the attached type) with the default
+// values for every setting.
+func (a *aspBase) defaults() (any, error) {
+	ptr := reflect.New(a.baseType)
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		DecodeHook:       a.decodeHook,
+		Result:           ptr.Interface(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = decoder.Decode(a.defaultsMap())
+	if err != nil {
+		return nil, err
+	}
+
+	return ptr.Interface(), nil
+}
+
+// envSettings returns the setting (or, for a collection, a setting for each
+// field of its items) with the environment variable(s) that can provide it.
+func (f *field) envSettings() []Setting {
+	if f.collection == nil {
+		return []Setting{f.setting()}
+	}
+
+	placeholder := "<N>"
+	if f.collection.keyed {
+		placeholder = "<NAME>"
+	}
+
+	settings := make([]Setting, 0, len(f.collection.leaves))
+	for _, l := range f.collection.leaves {
+		s := f.setting()
+		s.Field += "[" + placeholder + "]." + l.name
+		s.Key += "." + strings.ToLower(placeholder) + "." + strings.ToLower(l.name)
+		s.Env += "_" + placeholder + "_" + l.env
+		settings = append(settings, s)
+	}
+	return settings
+}
@@ -0,0 +1,196 @@
+package asp
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type commandTestConfig struct {
+	Host     string `asp.required:"true"`
+	Port     int    `asp.max:"65535"`
+	Password string `asp.sensitive:"true"`
+	Timeout  time.Duration
+	Tags     []string
+	Optional *int
+	Backends []Nested
+}
+
+func runConfigCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	cmd := &cobra.Command{Use: "app"}
+	err := Attach(cmd, commandTestConfig{Port: 80, Password: "default-secret", Timeout: time.Second})
+	assert.NoError(t, err)
+	AddConfigCommand(cmd)
+
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetErr(out)
+	cmd.SetArgs(args)
+	err = cmd.Execute()
+	return out.String(), err
+}
+
+func TestConfigCommandShow(t *testing.T) {
+	t.Setenv("APP_PASSWORD", "env-secret")
+	t.Setenv("APP_BACKENDS_0_DUMMY", "2")
+
+	out, err := runConfigCommand(t, "config", "show", "--host", "example.com", "--tags", "a,b")
+	assert.NoError(t, err)
+	assert.Equal(t, `host: example.com
+port: 80
+password: '[REDACTED]'
+timeout: 1s
+tags:
+  - a
+  - b
+backends:
+  - dummy: 2
+`, out)
+
+	out, err = runConfigCommand(t, "config", "show", "--host", "example.com", "--format", "toml")
+	assert.NoError(t, err)
+	assert.Equal(t, `host = 'example.com'
+port = 80
+password = '[REDACTED]'
+timeout = '1s'
+tags = []
+
+[[backends]]
+dummy = 2
+`, out)
+
+	_, err = runConfigCommand(t, "config", "show", "--host", "example.com", "--format", "ini")
+	assert.ErrorIs(t, err, ErrConfigFormatUnsupported)
+}
+
+func TestConfigCommandExplain(t *testing.T) {
+	t.Setenv("APP_PORT", "8080")
+
+	out, err := runConfigCommand(t, "config", "explain", "--port", "9000", "--host", "h")
+	assert.NoError(t, err)
+	assert.Contains(t, out, `port: flag --port="9000"
+    overrides env APP_PORT="8080"
+    overrides default="80"
+`)
+	assert.Contains(t, out, `password: default="[REDACTED]"`)
+	assert.Contains(t, out, `optional: (not set)`)
+}
+
+func TestConfigCommandInit(t *testing.T) {
+	expected := `# sets the host value (env: APP_HOST)
+host: ""
+# sets the port value (env: APP_PORT)
+port: 80
+# sets the password value (env: APP_PASSWORD)
+password: default-secret
+# sets the timeout value (env: APP_TIMEOUT)
+timeout: 1s
+# sets the tags value (env: APP_TAGS)
+tags: []
+# sets the backends value (env: APP_BACKENDS)
+backends: []
+`
+
+	out, err := runConfigCommand(t, "config", "init")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, out)
+
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	out, err = runConfigCommand(t, "config", "init", file)
+	assert.NoError(t, err)
+	assert.Equal(t, "wrote "+file+"\n", out)
+
+	b, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(b))
+
+	// no overwriting without --force
+	_, err = runConfigCommand(t, "config", "init", file)
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	_, err = runConfigCommand(t, "config", "init", "--force", file)
+	assert.NoError(t, err)
+
+	// the format comes from the extension
+	file = filepath.Join(t.TempDir(), "app.json")
+	_, err = runConfigCommand(t, "config", "init", file)
+	assert.NoError(t, err)
+
+	b, err = os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "backends": [],
+  "host": "",
+  "password": "default-secret",
+  "port": 80,
+  "tags": [],
+  "timeout": "1s"
+}
+`, string(b))
+}
+
+func TestConfigCommandValidate(t *testing.T) {
+	out, err := runConfigCommand(t, "config", "validate", "--host", "h")
+	assert.NoError(t, err)
+	assert.Equal(t, "configuration is valid\n", out)
+
+	_, err = runConfigCommand(t, "config", "validate")
+	assert.ErrorIs(t, err, ErrRequiredMissing)
+
+	_, err = runConfigCommand(t, "config", "validate", "--host", "h", "--port", "70000")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestConfigCommandEnv(t *testing.T) {
+	out, err := runConfigCommand(t, "config", "env")
+	assert.NoError(t, err)
+	assert.Equal(t, `ENV                     FLAG        KEY
+APP_HOST                --host      host
+APP_PORT                --port      port
+APP_PASSWORD            --password  password
+APP_TIMEOUT             --timeout   timeout
+APP_TAGS                --tags      tags
+APP_OPTIONAL            --optional  optional
+APP_BACKENDS_<N>_DUMMY  --backends  backends.<n>.dummy
+`, out)
+}
+
+func TestConfigCommandSchema(t *testing.T) {
+	out, err := runConfigCommand(t, "config", "schema")
+	assert.NoError(t, err)
+
+	schema := map[string]any{}
+	err = json.Unmarshal([]byte(out), &schema)
+	assert.NoError(t, err)
+	assert.Equal(t, "app", schema["title"])
+	assert.Equal(t, []any{"host"}, schema["required"])
+
+	properties := schema["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{
+		"type":        "integer",
+		"description": "sets the port value (env: APP_PORT)",
+		"default":     80.0,
+		"maximum":     65535.0,
+	}, properties["port"])
+}
+
+func TestConfigCommandNotAttached(t *testing.T) {
+	cmd := &cobra.Command{Use: "app"}
+	AddConfigCommand(cmd)
+
+	for _, sub := range []string{"show", "explain", "init", "validate", "env", "schema", "profiles"} {
+		cmd.SetArgs([]string{"config", sub})
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		err := cmd.Execute()
+		assert.ErrorIs(t, err, ErrNotAttached, sub)
+	}
+}
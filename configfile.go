@@ -0,0 +1,263 @@
+package asp
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrConfigFormatUnsupported indicates that a config file format other than
+// YAML, JSON, or TOML was requested.
+var ErrConfigFormatUnsupported = errors.New("config file format is unsupported (must be yaml, json, or toml)")
+
+// SensitiveMode specifies how [SerializeConfigFile] handles sensitive values.
+type SensitiveMode int
+
+const (
+	// SensitiveRedact replaces (non-empty) sensitive values with [REDACTED].
+	SensitiveRedact SensitiveMode = iota
+	// SensitiveOmit leaves (non-empty) sensitive values out entirely.
+	SensitiveOmit
+	// SensitiveInclude includes the actual sensitive values.
+	SensitiveInclude
+)
+
+// ConfigFileOptions are the options for [SerializeConfigFile].
+type ConfigFileOptions struct {
+	// OmitEmpty omits empty/zero values.
+	OmitEmpty bool
+
+	// Sensitive specifies how sensitive values are handled; by default, they
+	// are redacted.
+	Sensitive SensitiveMode
+
+	// Comments adds each field's description as a comment (YAML and TOML
+	// only, since JSON has no comments).
+	Comments bool
+
+	// EnvPrefix is the environment variable prefix used in the descriptions;
+	// it should be the same one passed to [WithEnvPrefix], or "APP" if none
+	// was.
+	EnvPrefix string
+}
+
+// SerializeConfigFile returns the config file content that would re-create
+// the given configuration values, in the given format ("yaml", "json", or
+// "toml"; a leading "." is allowed, so that [path/filepath.Ext] can be used).
+// The keys are the same ones that [Asp.Config] reads.  Optional fields that
+// are nil are always omitted.
+func SerializeConfigFile[T Config](cfg T, format string, options ConfigFileOptions) ([]byte, error) {
+	return serializeConfigFile(cfg, format, options)
+}
+
+// serializeConfigFile is the non-generic implementation of
+// [SerializeConfigFile].
+func serializeConfigFile(cfg any, format string, options ConfigFileOptions) ([]byte, error) {
+	format, err := configFileFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	v := reflect.ValueOf(cfg)
+	if reflect.Indirect(v).Kind() != reflect.Struct {
+		return nil, ErrConfigMustBeStruct
+	}
+
+	nodes, err := configNodes(v, nodeOptions{
+		omitEmpty: options.OmitEmpty,
+		sensitive: options.Sensitive,
+		comments:  options.Comments && format != "json",
+	}, attrs{env: strings.TrimRight(options.EnvPrefix, "_")})
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(nodesMap(nodes), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+
+	case "toml":
+		buf := &bytes.Buffer{}
+		err = writeTOMLTable(buf, nodes, nil)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	doc, err := yamlMapping(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	err = enc.Encode(doc)
+	if err != nil {
+		return nil, err
+	}
+	err = enc.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// configFileFormat normalizes the config file format.
+func configFileFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimPrefix(format, "."))
+
+	switch format {
+	case "yaml", "json", "toml":
+		return format, nil
+	case "yml":
+		return "yaml", nil
+	}
+
+	return "", errors.WithMessagef(ErrConfigFormatUnsupported, "%q", format)
+}
+
+// yamlMapping creates a YAML mapping node for the config nodes, with any
+// descriptions as head comments.
+func yamlMapping(nodes []*configNode) (*yaml.Node, error) {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, n := range nodes {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: n.key, HeadComment: n.desc}
+
+		var val *yaml.Node
+		var err error
+
+		switch n.kind {
+		case tableNode:
+			val, err = yamlMapping(n.children)
+
+		case listNode:
+			val = &yaml.Node{Kind: yaml.SequenceNode}
+			for _, item := range n.items {
+				var itemVal *yaml.Node
+				itemVal, err = yamlMapping(item)
+				if err != nil {
+					break
+				}
+				val.Content = append(val.Content, itemVal)
+			}
+
+		default:
+			val = &yaml.Node{}
+			err = val.Encode(n.value)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		mapping.Content = append(mapping.Content, key, val)
+	}
+
+	return mapping, nil
+}
+
+// writeTOMLTable writes the config nodes as the content of a TOML table.
+// TOML requires all of a table's plain values to come before any sub-tables,
+// so the nodes are written in two passes.
+func writeTOMLTable(buf *bytes.Buffer, nodes []*configNode, path []string) error {
+	// An empty list can't be written as an array of tables, but it can be
+	// written as an (empty) plain array.
+	isValue := func(n *configNode) bool {
+		return n.kind == leafNode || (n.kind == listNode && len(n.items) == 0)
+	}
+
+	for _, n := range nodes {
+		if !isValue(n) {
+			continue
+		}
+
+		value := n.value
+		if n.kind == listNode {
+			value = []any{}
+		}
+
+		writeTOMLComment(buf, n.desc)
+
+		enc := toml.NewEncoder(buf)
+		enc.SetTablesInline(true)
+		err := enc.Encode(map[string]any{n.key: value})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, n := range nodes {
+		if isValue(n) {
+			continue
+		}
+
+		childPath := append(append([]string{}, path...), tomlKey(n.key))
+		header := strings.Join(childPath, ".")
+
+		if n.kind == tableNode {
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			writeTOMLComment(buf, n.desc)
+			buf.WriteString("[" + header + "]\n")
+
+			err := writeTOMLTable(buf, n.children, childPath)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		for i, item := range n.items {
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			if i == 0 {
+				writeTOMLComment(buf, n.desc)
+			}
+			buf.WriteString("[[" + header + "]]\n")
+
+			err := writeTOMLTable(buf, item, childPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeTOMLComment writes the description (if any) as a TOML comment.
+func writeTOMLComment(buf *bytes.Buffer, desc string) {
+	if desc == "" {
+		return
+	}
+
+	for _, line := range strings.Split(desc, "\n") {
+		buf.WriteString("# " + line + "\n")
+	}
+}
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlKey quotes a TOML key, if needed.
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
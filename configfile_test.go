@@ -0,0 +1,198 @@
+package asp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type configFileTestConfig struct {
+	Host     string `asp.desc:"the host to connect to"`
+	Port     int
+	Timeout  time.Duration
+	Started  time.Time
+	Tags     []string
+	Labels   map[string]string
+	Secret   string `asp.sensitive:"true"`
+	Optional *int
+	Nested   struct {
+		Enabled bool
+	}
+	Upstreams []upstream
+	Databases map[string]database
+}
+
+func newConfigFileTestConfig() configFileTestConfig {
+	cfg := configFileTestConfig{
+		Host:      "example.com",
+		Port:      8080,
+		Timeout:   time.Minute,
+		Started:   time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC),
+		Tags:      []string{"a", "b"},
+		Labels:    map[string]string{"team": "core"},
+		Secret:    "shh",
+		Upstreams: []upstream{{Host: "one", Weight: 2}},
+		Databases: map[string]database{"primary": {Host: "db", Password: "pw"}},
+	}
+	cfg.Nested.Enabled = true
+	return cfg
+}
+
+func TestSerializeConfigFile(t *testing.T) {
+	cfg := newConfigFileTestConfig()
+
+	b, err := SerializeConfigFile(cfg, "yaml", ConfigFileOptions{OmitEmpty: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `host: example.com
+port: 8080
+timeout: 1m0s
+started: "2000-01-02T03:04:05Z"
+tags:
+  - a
+  - b
+labels:
+  team: core
+secret: '[REDACTED]'
+nested:
+  enabled: true
+upstreams:
+  - host: one
+    weight: 2
+databases:
+  primary:
+    host: db
+    password: '[REDACTED]'
+`, string(b))
+
+	b, err = SerializeConfigFile(cfg, ".toml", ConfigFileOptions{OmitEmpty: true, Sensitive: SensitiveOmit})
+	assert.NoError(t, err)
+	assert.Equal(t, `host = 'example.com'
+port = 8080
+timeout = '1m0s'
+started = '2000-01-02T03:04:05Z'
+tags = ['a', 'b']
+labels = {team = 'core'}
+
+[nested]
+enabled = true
+
+[[upstreams]]
+host = 'one'
+weight = 2
+
+[databases]
+
+[databases.primary]
+host = 'db'
+`, string(b))
+
+	b, err = SerializeConfigFile(configFileTestConfig{Port: 1}, "JSON", ConfigFileOptions{OmitEmpty: true, Comments: true})
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "port": 1
+}
+`, string(b))
+}
+
+func TestSerializeConfigFileComments(t *testing.T) {
+	cfg := configFileTestConfig{Port: 1, Upstreams: []upstream{{Host: "one"}}}
+	cfg.Nested.Enabled = true
+
+	b, err := SerializeConfigFile(cfg, "yml", ConfigFileOptions{OmitEmpty: true, Comments: true, EnvPrefix: "MY_"})
+	assert.NoError(t, err)
+	assert.Equal(t, `# sets the port value (env: MY_PORT)
+port: 1
+nested:
+  # sets the nested enabled value (env: MY_NESTED_ENABLED)
+  enabled: true
+# sets the upstreams value (env: MY_UPSTREAMS)
+upstreams:
+  - host: one
+`, string(b))
+
+	b, err = SerializeConfigFile(cfg, "toml", ConfigFileOptions{OmitEmpty: true, Comments: true, EnvPrefix: "MY"})
+	assert.NoError(t, err)
+	assert.Equal(t, `# sets the port value (env: MY_PORT)
+port = 1
+
+[nested]
+# sets the nested enabled value (env: MY_NESTED_ENABLED)
+enabled = true
+
+# sets the upstreams value (env: MY_UPSTREAMS)
+[[upstreams]]
+host = 'one'
+`, string(b))
+
+	_, err = SerializeConfigFile(struct {
+		Bad string `asp.desc:"{{ .Bogus"`
+	}{}, "yaml", ConfigFileOptions{Comments: true})
+	assert.Error(t, err)
+}
+
+func TestSerializeConfigFileSensitive(t *testing.T) {
+	cfg := withSensitive{Public: "public", Private: "private"}
+
+	cases := map[SensitiveMode]string{
+		SensitiveRedact:  "public: public\nprivate: '[REDACTED]'\n",
+		SensitiveOmit:    "public: public\n",
+		SensitiveInclude: "public: public\nprivate: private\n",
+	}
+
+	for mode, expected := range cases {
+		b, err := SerializeConfigFile(cfg, "yaml", ConfigFileOptions{Sensitive: mode})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(b))
+	}
+
+	// empty sensitive values are just empty
+	b, err := SerializeConfigFile(withSensitive{}, "yaml", ConfigFileOptions{Sensitive: SensitiveOmit})
+	assert.NoError(t, err)
+	assert.Equal(t, "public: \"\"\nprivate: \"\"\n", string(b))
+}
+
+func TestSerializeConfigFileErrors(t *testing.T) {
+	_, err := SerializeConfigFile(configFileTestConfig{}, "ini", ConfigFileOptions{})
+	assert.ErrorIs(t, err, ErrConfigFormatUnsupported)
+
+	_, err = SerializeConfigFile(1, "yaml", ConfigFileOptions{})
+	assert.ErrorIs(t, err, ErrConfigMustBeStruct)
+}
+
+func TestSerializeConfigFileRoundTrip(t *testing.T) {
+	expected := newConfigFileTestConfig()
+	one := 1
+	expected.Optional = &one
+
+	for _, format := range []string{"yaml", "json", "toml"} {
+		t.Run(format, func(t *testing.T) {
+			b, err := SerializeConfigFile(expected, format, ConfigFileOptions{Sensitive: SensitiveInclude, Comments: true})
+			assert.NoError(t, err)
+
+			file := filepath.Join(t.TempDir(), "config."+format)
+			err = os.WriteFile(file, b, 0o600)
+			assert.NoError(t, err)
+
+			cmd := &cobra.Command{}
+			a, err := AttachInstance(cmd, configFileTestConfig{})
+			assert.NoError(t, err)
+
+			err = cmd.ParseFlags([]string{"--config", file})
+			assert.NoError(t, err)
+
+			actual, err := a.Config()
+			assert.NoError(t, err)
+			assert.Equal(t, expected, *actual)
+		})
+	}
+}
+
+func TestTOMLKey(t *testing.T) {
+	assert.Equal(t, "simple_key-1", tomlKey("simple_key-1"))
+	assert.Equal(t, `"with space"`, tomlKey("with space"))
+	assert.Equal(t, `"dotted.key"`, tomlKey("dotted.key"))
+}
@@ -14,14 +14,18 @@ package decoders
 
 import (
 	// "builtin"
+	"encoding"
 	"encoding/hex"
 	"fmt"
+	"net"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/pflag"
 )
 
 // Note that even for the implementations that extend from the default
@@ -124,11 +128,17 @@ func StringToMapStringString() mapstructure.DecodeHookFuncValue {
 
 // StringToSlice is similar to [mapstructure.StringToSliceHookFunc], but can
 // also handle input wrapped in square brackets, which sometimes happens during
-// CLI flag serialization.
+// CLI flag serialization.  In addition to `[]string`, this splits strings
+// destined for a slice of any other scalar type (`[]int`, `[]float64`,
+// `[]bool`, etc.) or of a type that can be decoded from a string (`[]net.IP`,
+// `[]netip.Prefix`, etc.), leaving the per-element conversion to
+// mapstructure.  (Note that `[]byte` is *not* included; see
+// [StringToByteSlice].)
 func StringToSlice(sep string) mapstructure.DecodeHookFuncValue {
 	return func(from reflect.Value, to reflect.Value) (interface{}, error) {
 		if from.Kind() != reflect.String ||
-			to.Type() != reflect.TypeOf([]string{}) {
+			to.Kind() != reflect.Slice ||
+			!isListElem(to.Type().Elem()) {
 			return from.Interface(), nil
 		}
 
@@ -136,6 +146,75 @@ func StringToSlice(sep string) mapstructure.DecodeHookFuncValue {
 	}
 }
 
+// isListElem reports whether a slice of the type can be decoded from a list
+// of strings.
+func isListElem(t reflect.Type) bool {
+	switch t {
+	case reflect.TypeOf(net.IPNet{}), reflect.TypeOf(url.URL{}):
+		return true
+	}
+
+	if reflect.PointerTo(t).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()) {
+		return true
+	}
+
+	k := t.Kind()
+	return k == reflect.String || k == reflect.Bool || (isNumberKind(k) && k != reflect.Uint8)
+}
+
+// StringToFlagValue decodes a string into any type that implements
+// [pflag.Value] (on its pointer), using the type's own Set method.  This is the
+// counterpart to the flag that asp creates for such types, so that
+// environment variables and config files parse exactly the same way that the
+// command-line does.
+func StringToFlagValue() mapstructure.DecodeHookFuncValue {
+	return func(from reflect.Value, to reflect.Value) (interface{}, error) {
+		// We don't want to re-parse values that are already the right type
+		// (like defaults), even if it's a string-based type.
+		if from.Kind() != reflect.String || from.Type() == to.Type() {
+			return from.Interface(), nil
+		}
+
+		ptr := reflect.New(to.Type())
+		flagValue, ok := ptr.Interface().(pflag.Value)
+		if !ok {
+			return from.Interface(), nil
+		}
+
+		err := flagValue.Set(from.String())
+		if err != nil {
+			return nil, err
+		}
+
+		return ptr.Elem().Interface(), nil
+	}
+}
+
+// StringToTextUnmarshaler is similar to
+// [mapstructure.TextUnmarshallerHookFunc], but it does not attempt to re-parse
+// string-based values that are already the destination type (like the default
+// values asp registers).
+func StringToTextUnmarshaler() mapstructure.DecodeHookFuncValue {
+	return func(from reflect.Value, to reflect.Value) (interface{}, error) {
+		if from.Kind() != reflect.String || from.Type() == to.Type() {
+			return from.Interface(), nil
+		}
+
+		ptr := reflect.New(to.Type())
+		unmarshaler, ok := ptr.Interface().(encoding.TextUnmarshaler)
+		if !ok {
+			return from.Interface(), nil
+		}
+
+		err := unmarshaler.UnmarshalText([]byte(from.String()))
+		if err != nil {
+			return nil, err
+		}
+
+		return ptr.Elem().Interface(), nil
+	}
+}
+
 // getListEntries is a helper for the string to map/slice decoders, which all
 // need to check for enclosing "[]" and then split.
 func getListEntries(s string, sep string) []string {
@@ -1,7 +1,12 @@
 package decoders
 
 import (
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -108,6 +113,15 @@ func TestStringToSlice(t *testing.T) {
 		"empty":                 {"", []string{}, nil, []string{}},
 		"to string passthrough": {"one,two", "", nil, "one,two"},
 		"from int passthrough":  {1, []string{}, nil, 1},
+		"to int slice":          {"1,2", []int{}, nil, []string{"1", "2"}},
+		"to float64 slice":      {"[1.5,2]", []float64{}, nil, []string{"1.5", "2"}},
+		"to bool slice":         {"true,false", []bool{}, nil, []string{"true", "false"}},
+		"to byte passthrough":   {"0102", []byte{}, nil, "0102"},
+		"to ip slice":           {"1.2.3.4,::1", []net.IP{}, nil, []string{"1.2.3.4", "::1"}},
+		"to ipnet slice":        {"10.0.0.0/8", []net.IPNet{}, nil, []string{"10.0.0.0/8"}},
+		"to prefix slice":       {"10.0.0.0/8", []netip.Prefix{}, nil, []string{"10.0.0.0/8"}},
+		"to url slice":          {"http://a,http://b", []url.URL{}, nil, []string{"http://a", "http://b"}},
+		"to struct passthrough": {"one,two", []struct{}{}, nil, "one,two"},
 	})
 
 	// t.Parallel()
@@ -143,3 +157,48 @@ func TestStringToSlice(t *testing.T) {
 	// 	})
 	// }
 }
+
+// flagValue is a trivial pflag.Value implementation.
+type flagValue struct {
+	parts []string
+}
+
+func (f *flagValue) Set(s string) error {
+	if s == "" {
+		return errors.New("empty")
+	}
+	f.parts = strings.Split(s, ":")
+	return nil
+}
+func (f *flagValue) String() string { return strings.Join(f.parts, ":") }
+func (f *flagValue) Type() string   { return "parts" }
+
+func TestStringToFlagValue(t *testing.T) {
+	runCases(t, true, StringToFlagValue(), decoderCases{
+		"simple":                {"a:b", flagValue{}, nil, flagValue{[]string{"a", "b"}}},
+		"set err":               {"", flagValue{}, assert.AnError, nil},
+		"to string passthrough": {"a:b", "", nil, "a:b"},
+		"from int passthrough":  {1, flagValue{}, nil, 1},
+	})
+}
+
+// upper is a string-based encoding.TextUnmarshaler
+type upper string
+
+func (u *upper) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		return errors.New("empty")
+	}
+	*u = upper(strings.ToUpper(string(b)))
+	return nil
+}
+
+func TestStringToTextUnmarshaler(t *testing.T) {
+	runCases(t, true, StringToTextUnmarshaler(), decoderCases{
+		"simple":                {"abc", upper(""), nil, upper("ABC")},
+		"unmarshal err":         {"", upper(""), assert.AnError, nil},
+		"same type passthrough": {upper(""), upper(""), nil, upper("")},
+		"to string passthrough": {"abc", "", nil, "abc"},
+		"from int passthrough":  {1, upper(""), nil, 1},
+	})
+}
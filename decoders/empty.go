@@ -0,0 +1,42 @@
+package decoders
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// helpers for values whose parsing rejects an empty string...
+type emptyValue struct {
+	pflag.Value
+	reset func()
+	empty bool
+}
+
+// NewEmptyValue wraps a [pflag.Value] (like pflag's own IP and map values)
+// whose Set rejects an empty string, so that an empty string instead calls
+// reset, which should set the underlying value to its empty (or zero) value.
+func NewEmptyValue(value pflag.Value, reset func()) *emptyValue {
+	return &emptyValue{Value: value, reset: reset}
+}
+
+// Set resets the value for an empty string, and uses the wrapped value's Set
+// otherwise.
+func (e *emptyValue) Set(s string) error {
+	if s == "" {
+		e.reset()
+		e.empty = true
+		return nil
+	}
+
+	e.empty = false
+	return e.Value.Set(s)
+}
+
+// String renders a reset value as an empty string (rather than, say, the
+// "<nil>" that a nil [net.IP] renders as), and uses the wrapped value's String
+// otherwise.
+func (e *emptyValue) String() string {
+	if e.empty {
+		return ""
+	}
+	return e.Value.String()
+}
@@ -0,0 +1,29 @@
+package decoders
+
+import (
+	"net"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEmptyValue(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	ip := net.ParseIP("10.0.0.1")
+	flags.IPVar(&ip, "ip", ip, "")
+
+	v := NewEmptyValue(flags.Lookup("ip").Value, func() { ip = nil })
+	assert.Equal(t, "ip", v.Type())
+	assert.Equal(t, "10.0.0.1", v.String())
+
+	assert.NoError(t, v.Set(""))
+	assert.Nil(t, ip)
+	assert.Equal(t, "", v.String())
+
+	assert.NoError(t, v.Set("::1"))
+	assert.Equal(t, net.ParseIP("::1"), ip)
+	assert.Equal(t, "::1", v.String())
+
+	assert.Error(t, v.Set("bogus"))
+}
@@ -0,0 +1,99 @@
+package decoders
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// Most of the network types (net.IP, net.IPNet, and the netip types) are
+// handled by the mapstructure-provided decode hooks; url.URL is the exception,
+// since mapstructure only handles *url.URL.
+
+// EmptyStringToIP decodes an empty string (as from an empty flag) as a nil
+// [net.IP], or the zero value of [net.IPNet] or the netip types, all of which
+// the mapstructure-provided hooks reject.
+func EmptyStringToIP() mapstructure.DecodeHookFuncValue {
+	return func(from reflect.Value, to reflect.Value) (interface{}, error) {
+		if from.Kind() != reflect.String || from.String() != "" {
+			return from.Interface(), nil
+		}
+
+		switch to.Type() {
+		case reflect.TypeOf(net.IP{}):
+			return net.IP(nil), nil
+		case reflect.TypeOf(net.IPNet{}):
+			return net.IPNet{}, nil
+		case reflect.TypeOf(netip.Addr{}):
+			return netip.Addr{}, nil
+		case reflect.TypeOf(netip.Prefix{}):
+			return netip.Prefix{}, nil
+		case reflect.TypeOf(netip.AddrPort{}):
+			return netip.AddrPort{}, nil
+		}
+
+		return from.Interface(), nil
+	}
+}
+
+// helpers for url.URL values...
+type urlValue url.URL
+
+// NewURLValue returns an interface around [url.URL] that supports [cobra] and
+// the [pflag.Value] interface.
+func NewURLValue(value url.URL) *urlValue {
+	v := urlValue(value)
+	return &v
+}
+
+// Set parses the string as a URL.
+func (u *urlValue) Set(s string) error {
+	v, err := parseURL(s)
+	if err != nil {
+		return err
+	}
+
+	*u = urlValue(v)
+	return nil
+}
+
+// Type returns "url" for the type of the value.
+func (u *urlValue) Type() string {
+	return "url"
+}
+
+// String renders the URL value as a string.
+func (u *urlValue) String() string {
+	return (*url.URL)(u).String()
+}
+
+// NewURLSliceValue returns a [pflag.Value] for a slice of [url.URL].
+func NewURLSliceValue(value []url.URL) *sliceValue[url.URL] {
+	s := NewSliceValue(value, parseURL, func(u url.URL) string { return u.String() })
+	s.typeName = "urlSlice"
+	return s
+}
+
+// StringToURL decodes a string as a [url.URL] value; see
+// [mapstructure.StringToURLHookFunc] for the *url.URL equivalent.
+func StringToURL() mapstructure.DecodeHookFuncValue {
+	return func(from reflect.Value, to reflect.Value) (interface{}, error) {
+		if from.Kind() != reflect.String ||
+			to.Type() != reflect.TypeOf(url.URL{}) {
+			return from.Interface(), nil
+		}
+
+		return parseURL(from.String())
+	}
+}
+
+func parseURL(s string) (url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return url.URL{}, err
+	}
+	return *u, nil
+}
@@ -0,0 +1,69 @@
+package decoders
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringToURL(t *testing.T) {
+	expected := url.URL{Scheme: "https", Host: "example.com", Path: "/path"}
+
+	runCases(t, true, StringToURL(), decoderCases{
+		"simple":                {"https://example.com/path", url.URL{}, nil, expected},
+		"parse err":             {"://bogus", url.URL{}, assert.AnError, nil},
+		"to string passthrough": {"https://example.com", "", nil, "https://example.com"},
+		"from int passthrough":  {1, url.URL{}, nil, 1},
+	})
+}
+
+func TestEmptyStringToIP(t *testing.T) {
+	runCases(t, true, EmptyStringToIP(), decoderCases{
+		"ip":                    {"", net.IP{}, nil, net.IP(nil)},
+		"ip net":                {"", net.IPNet{}, nil, net.IPNet{}},
+		"addr":                  {"", netip.Addr{}, nil, netip.Addr{}},
+		"prefix":                {"", netip.Prefix{}, nil, netip.Prefix{}},
+		"addr port":             {"", netip.AddrPort{}, nil, netip.AddrPort{}},
+		"non-empty passthrough": {"10.0.0.1", net.IP{}, nil, "10.0.0.1"},
+		"to string passthrough": {"", "x", nil, ""},
+	})
+}
+
+func TestURLValue(t *testing.T) {
+	v := NewURLValue(url.URL{})
+	assert.Equal(t, "url", v.Type())
+	assert.Equal(t, "", v.String())
+
+	assert.NoError(t, v.Set("http://localhost:8080"))
+	assert.Equal(t, "http://localhost:8080", v.String())
+	assert.Equal(t, "localhost:8080", v.Host)
+
+	assert.Error(t, v.Set("://bogus"))
+	assert.Equal(t, "http://localhost:8080", v.String())
+}
+
+func TestURLSliceValue(t *testing.T) {
+	v := NewURLSliceValue(nil)
+	assert.Equal(t, "urlSlice", v.Type())
+
+	assert.NoError(t, v.Set("http://one,http://two"))
+	assert.Equal(t, "[http://one,http://two]", v.String())
+	assert.Error(t, v.Set("://bogus"))
+}
+
+func TestTextSliceValue(t *testing.T) {
+	v := NewTextSliceValue([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	assert.Equal(t, "prefixSlice", v.Type())
+	assert.Equal(t, "[10.0.0.0/8]", v.String())
+
+	assert.NoError(t, v.Set("192.168.0.0/16,fd00::/8"))
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("192.168.0.0/16"),
+		netip.MustParsePrefix("fd00::/8"),
+	}, v.value)
+
+	assert.Error(t, v.Set("bogus"))
+}
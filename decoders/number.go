@@ -0,0 +1,125 @@
+package decoders
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+type signed interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+type unsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+type float interface {
+	~float32 | ~float64
+}
+
+// NewIntSliceValue returns a [pflag.Value] for a slice of any signed integer
+// type; pflag itself only handles `[]int`, `[]int32`, and `[]int64`, and
+// doesn't parse an empty string as an empty slice.
+func NewIntSliceValue[T signed](value []T) *sliceValue[T] {
+	bits := reflect.TypeOf(T(0)).Bits()
+	return NewSliceValue(
+		value,
+		func(s string) (T, error) {
+			i, err := strconv.ParseInt(s, 10, bits)
+			return T(i), err
+		},
+		func(v T) string { return strconv.FormatInt(int64(v), 10) },
+	)
+}
+
+// NewUintSliceValue returns a [pflag.Value] for a slice of any unsigned
+// integer type; pflag itself only handles `[]uint`, and doesn't parse an empty
+// string as an empty slice.
+func NewUintSliceValue[T unsigned](value []T) *sliceValue[T] {
+	bits := reflect.TypeOf(T(0)).Bits()
+	return NewSliceValue(
+		value,
+		func(s string) (T, error) {
+			u, err := strconv.ParseUint(s, 10, bits)
+			return T(u), err
+		},
+		func(v T) string { return strconv.FormatUint(uint64(v), 10) },
+	)
+}
+
+// NewFloatSliceValue returns a [pflag.Value] for a slice of either float type.
+// Unlike pflag's own float slices, an empty string parses as an empty slice.
+func NewFloatSliceValue[T float](value []T) *sliceValue[T] {
+	bits := reflect.TypeOf(T(0)).Bits()
+	return NewSliceValue(
+		value,
+		func(s string) (T, error) {
+			f, err := strconv.ParseFloat(s, bits)
+			return T(f), err
+		},
+		func(v T) string { return strconv.FormatFloat(float64(v), 'g', -1, bits) },
+	)
+}
+
+// NumericRange ensures that numeric values (as from YAML, JSON, or TOML config
+// files) fit in the destination's sized numeric type, returning an error
+// rather than allowing [mapstructure] to silently wrap (or truncate) the
+// value.  String values are already range-checked by mapstructure's parsing.
+func NumericRange() mapstructure.DecodeHookFuncValue {
+	return func(from reflect.Value, to reflect.Value) (interface{}, error) {
+		if !isNumberKind(from.Kind()) || !isNumberKind(to.Kind()) {
+			return from.Interface(), nil
+		}
+
+		inRange := true
+
+		switch to.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			switch {
+			case from.CanInt():
+				inRange = !to.OverflowInt(from.Int())
+			case from.CanUint():
+				inRange = from.Uint() <= math.MaxInt64 && !to.OverflowInt(int64(from.Uint()))
+			case from.CanFloat():
+				f := from.Float()
+				inRange = f >= math.MinInt64 && f < math.MaxInt64 && !to.OverflowInt(int64(f))
+			}
+
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			switch {
+			case from.CanInt():
+				inRange = from.Int() >= 0 && !to.OverflowUint(uint64(from.Int()))
+			case from.CanUint():
+				inRange = !to.OverflowUint(from.Uint())
+			case from.CanFloat():
+				f := from.Float()
+				inRange = f >= 0 && f < math.MaxUint64 && !to.OverflowUint(uint64(f))
+			}
+
+		case reflect.Float32:
+			if from.CanFloat() {
+				inRange = !to.OverflowFloat(from.Float())
+			}
+		}
+
+		if !inRange {
+			return nil, fmt.Errorf("value %v is out of range for %s", from.Interface(), to.Type())
+		}
+
+		return from.Interface(), nil
+	}
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
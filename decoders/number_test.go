@@ -0,0 +1,82 @@
+package decoders
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumericRange(t *testing.T) {
+	runCases(t, true, NumericRange(), decoderCases{
+		"int to int8":              {127, int8(0), nil, 127},
+		"int to int8 overflow":     {128, int8(0), assert.AnError, nil},
+		"int to int8 underflow":    {-129, int8(0), assert.AnError, nil},
+		"int to uint8":             {255, uint8(0), nil, 255},
+		"int to uint8 overflow":    {256, uint8(0), assert.AnError, nil},
+		"int to uint negative":     {-1, uint(0), assert.AnError, nil},
+		"uint64 to int64 overflow": {uint64(math.MaxUint64), int64(0), assert.AnError, nil},
+		"uint to int16":            {uint(300), int16(0), nil, uint(300)},
+		"float to int32":           {float64(1e9), int32(0), nil, float64(1e9)},
+		"float to int32 overflow":  {float64(1e10), int32(0), assert.AnError, nil},
+		"float to uint16 negative": {float64(-1), uint16(0), assert.AnError, nil},
+		"float64 to float32":       {float64(1.5), float32(0), nil, float64(1.5)},
+		"float32 overflow":         {math.MaxFloat64, float32(0), assert.AnError, nil},
+		"int to float32":           {1, float32(0), nil, 1},
+		"string passthrough":       {"1000", int8(0), nil, "1000"},
+		"to string passthrough":    {1000, "", nil, 1000},
+	})
+}
+
+func TestNewIntSliceValue(t *testing.T) {
+	v := NewIntSliceValue([]int8{1, 2})
+	assert.Equal(t, "int8Slice", v.Type())
+	assert.Equal(t, "[1,2]", v.String())
+
+	assert.NoError(t, v.Set("3,-4"))
+	assert.Equal(t, []int8{3, -4}, v.value)
+	assert.Error(t, v.Set("128"))
+
+	v16 := NewIntSliceValue([]int16{})
+	assert.Equal(t, "int16Slice", v16.Type())
+	assert.NoError(t, v16.Set("32767"))
+	assert.Error(t, v16.Set("32768"))
+
+	// base 10, like pflag's own int slices
+	v8 := NewIntSliceValue([]int8{})
+	assert.NoError(t, v8.Set("010,08"))
+	assert.Equal(t, []int8{10, 8}, v8.value)
+	assert.Error(t, NewIntSliceValue([]int8{}).Set("0x10"))
+}
+
+func TestNewUintSliceValue(t *testing.T) {
+	v := NewUintSliceValue([]uint16{1, 2})
+	assert.Equal(t, "uint16Slice", v.Type())
+	assert.Equal(t, "[1,2]", v.String())
+
+	assert.NoError(t, v.Set("3,4"))
+	assert.Equal(t, []uint16{3, 4}, v.value)
+	assert.Error(t, v.Set("-1"))
+	assert.Error(t, v.Set("65536"))
+
+	// base 10, like pflag's own uint slice
+	v8 := NewUintSliceValue([]uint8{})
+	assert.NoError(t, v8.Set("010,08"))
+	assert.Equal(t, []uint8{10, 8}, v8.value)
+	assert.Error(t, NewUintSliceValue([]uint8{}).Set("0x10"))
+}
+
+func TestNewFloatSliceValue(t *testing.T) {
+	v := NewFloatSliceValue([]float32{1.5})
+	assert.Equal(t, "float32Slice", v.Type())
+	assert.Equal(t, "[1.5]", v.String())
+
+	assert.NoError(t, v.Set("0.1,-2"))
+	assert.Equal(t, []float32{0.1, -2}, v.value)
+	assert.Error(t, v.Set("x"))
+
+	// unlike pflag's, an empty string is an empty slice
+	v = NewFloatSliceValue([]float32{1.5})
+	assert.NoError(t, v.Set(""))
+	assert.Equal(t, []float32{}, v.value)
+}
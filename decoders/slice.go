@@ -0,0 +1,112 @@
+package decoders
+
+import (
+	"reflect"
+	"strings"
+)
+
+// helpers for slice values that pflag doesn't natively support...
+type sliceValue[T any] struct {
+	value    []T
+	changed  bool
+	typeName string
+	parse    func(string) (T, error)
+	format   func(T) string
+}
+
+// NewSliceValue returns a [pflag.Value] (and [pflag.SliceValue]) for a slice
+// of any type, given functions to parse and format the individual elements.
+// Like the pflag-provided slice values, the first time the flag is set it
+// replaces the default value, and any subsequent uses append to it.
+func NewSliceValue[T any](value []T, parse func(string) (T, error), format func(T) string) *sliceValue[T] {
+	return &sliceValue[T]{
+		value:    value,
+		typeName: typeName[T]() + "Slice",
+		parse:    parse,
+		format:   format,
+	}
+}
+
+// Set parses the comma-separated string into elements, and either replaces or
+// appends to the existing value.
+func (s *sliceValue[T]) Set(val string) error {
+	out, err := s.parseAll(getListEntries(val, ","))
+	if err != nil {
+		return err
+	}
+
+	if !s.changed {
+		s.value = out
+	} else {
+		s.value = append(s.value, out...)
+	}
+	s.changed = true
+	return nil
+}
+
+// Type returns the element type name with a "Slice" suffix, like
+// "int8Slice".
+func (s *sliceValue[T]) Type() string {
+	return s.typeName
+}
+
+// String renders the slice value in the same "[a,b,c]" format that the
+// pflag-provided slice values use.
+func (s *sliceValue[T]) String() string {
+	return "[" + strings.Join(s.GetSlice(), ",") + "]"
+}
+
+// Append parses and adds a single element to the slice.
+func (s *sliceValue[T]) Append(val string) error {
+	v, err := s.parse(val)
+	if err != nil {
+		return err
+	}
+	s.value = append(s.value, v)
+	return nil
+}
+
+// Replace parses and replaces all of the elements in the slice.
+func (s *sliceValue[T]) Replace(vals []string) error {
+	out, err := s.parseAll(vals)
+	if err != nil {
+		return err
+	}
+	s.value = out
+	return nil
+}
+
+// GetSlice returns the elements of the slice as formatted strings.
+func (s *sliceValue[T]) GetSlice() []string {
+	out := make([]string, 0, len(s.value))
+	for _, v := range s.value {
+		out = append(out, s.format(v))
+	}
+	return out
+}
+
+func (s *sliceValue[T]) parseAll(vals []string) ([]T, error) {
+	out := make([]T, 0, len(vals))
+	for _, val := range vals {
+		v, err := s.parse(val)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// typeName returns the lower-camel-case name of T, like "int8" or "addr".
+func typeName[T any]() string {
+	name := reflect.TypeOf((*T)(nil)).Elem().Name()
+	if name == "" {
+		return "value"
+	}
+	return lowerFirst(name)
+}
+
+// lowerFirst lower-cases the first character of a (type) name.
+func lowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
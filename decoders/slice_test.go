@@ -0,0 +1,43 @@
+package decoders
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSliceValue(t *testing.T) {
+	v := NewSliceValue([]int{1}, strconv.Atoi, strconv.Itoa)
+	assert.Equal(t, "intSlice", v.Type())
+	assert.Equal(t, "[1]", v.String())
+
+	// first set replaces, subsequent sets append
+	assert.NoError(t, v.Set("2,3"))
+	assert.NoError(t, v.Set("[4]"))
+	assert.Equal(t, []int{2, 3, 4}, v.value)
+	assert.Equal(t, []string{"2", "3", "4"}, v.GetSlice())
+
+	assert.NoError(t, v.Append("5"))
+	assert.Equal(t, []int{2, 3, 4, 5}, v.value)
+
+	assert.NoError(t, v.Replace([]string{"6", "7"}))
+	assert.Equal(t, []int{6, 7}, v.value)
+
+	assert.Error(t, v.Set("bogus"))
+	assert.Error(t, v.Append("bogus"))
+	assert.Error(t, v.Replace([]string{"bogus"}))
+	assert.Equal(t, []int{6, 7}, v.value)
+}
+
+func TestSliceValueUnnamedType(t *testing.T) {
+	parseErr := errors.New("never parses")
+	v := NewSliceValue(
+		[]struct{}{},
+		func(string) (struct{}, error) { return struct{}{}, parseErr },
+		func(struct{}) string { return "" },
+	)
+	assert.Equal(t, "valueSlice", v.Type())
+	assert.ErrorIs(t, v.Set("x"), parseErr)
+}
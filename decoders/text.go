@@ -0,0 +1,68 @@
+package decoders
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+)
+
+// helpers for encoding.TextUnmarshaler values...
+type textValue struct {
+	value encoding.TextUnmarshaler
+}
+
+// NewTextValue returns an interface around any [encoding.TextUnmarshaler] that
+// supports [cobra] and the [pflag.Value] interface.  Unlike
+// [pflag.FlagSet.TextVar], the value does *not* need to also implement
+// [encoding.TextMarshaler], although it will be used (for String) if it does.
+func NewTextValue(value encoding.TextUnmarshaler) *textValue {
+	return &textValue{value}
+}
+
+// Set uses the value's UnmarshalText to parse the string.
+func (t *textValue) Set(s string) error {
+	return t.value.UnmarshalText([]byte(s))
+}
+
+// Type returns the lower-camel-case name of the underlying type, like
+// "level".
+func (t *textValue) Type() string {
+	name := reflect.Indirect(reflect.ValueOf(t.value)).Type().Name()
+	if name == "" {
+		return "text"
+	}
+	return lowerFirst(name)
+}
+
+// String renders the value using MarshalText if available, and falls back to
+// fmt's default formatting otherwise.
+func (t *textValue) String() string {
+	if m, ok := t.value.(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(reflect.Indirect(reflect.ValueOf(t.value)).Interface())
+}
+
+// textPointer is the constraint for a pointer-to-T that is an
+// [encoding.TextUnmarshaler].
+type textPointer[T any] interface {
+	*T
+	encoding.TextUnmarshaler
+}
+
+// NewTextSliceValue returns a [pflag.Value] for a slice of any type whose
+// pointer implements [encoding.TextUnmarshaler], like [netip.Addr].
+func NewTextSliceValue[T any, PT textPointer[T]](value []T) *sliceValue[T] {
+	return NewSliceValue(
+		value,
+		func(s string) (T, error) {
+			var v T
+			err := PT(&v).UnmarshalText([]byte(s))
+			return v, err
+		},
+		func(v T) string { return NewTextValue(PT(&v)).String() },
+	)
+}
@@ -0,0 +1,50 @@
+package decoders
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unmarshalOnly implements encoding.TextUnmarshaler but *not*
+// encoding.TextMarshaler.
+type unmarshalOnly struct {
+	s string
+}
+
+func (u *unmarshalOnly) UnmarshalText(b []byte) error {
+	if len(b) == 0 {
+		return errors.New("empty")
+	}
+	u.s = strings.ToUpper(string(b))
+	return nil
+}
+
+func TestTextValue(t *testing.T) {
+	level := slog.LevelInfo
+	v := NewTextValue(&level)
+
+	assert.Equal(t, "level", v.Type())
+	assert.Equal(t, "INFO", v.String())
+
+	assert.NoError(t, v.Set("debug"))
+	assert.Equal(t, slog.LevelDebug, level)
+	assert.Equal(t, "DEBUG", v.String())
+
+	assert.Error(t, v.Set("bogus"))
+}
+
+func TestTextValueUnmarshalOnly(t *testing.T) {
+	u := unmarshalOnly{}
+	v := NewTextValue(&u)
+
+	assert.Equal(t, "unmarshalOnly", v.Type())
+	assert.NoError(t, v.Set("abc"))
+	assert.Equal(t, "ABC", u.s)
+	assert.Equal(t, "{ABC}", v.String())
+
+	assert.Error(t, v.Set(""))
+}
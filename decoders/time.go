@@ -58,3 +58,10 @@ func timeConv(s string) (interface{}, error) {
 
 	return time.Parse(TimeLayout, s)
 }
+
+// NewDurationSliceValue returns a [pflag.Value] for a slice of
+// [time.Duration].  Unlike pflag's own duration slice, an empty string parses
+// as an empty slice.
+func NewDurationSliceValue(value []time.Duration) *sliceValue[time.Duration] {
+	return NewSliceValue(value, time.ParseDuration, time.Duration.String)
+}
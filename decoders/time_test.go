@@ -91,3 +91,16 @@ func TestTimeConv(t *testing.T) {
 		})
 	}
 }
+
+func TestNewDurationSliceValue(t *testing.T) {
+	v := NewDurationSliceValue([]time.Duration{time.Second})
+	assert.Equal(t, "durationSlice", v.Type())
+	assert.Equal(t, "[1s]", v.String())
+
+	assert.NoError(t, v.Set("1m,2h"))
+	assert.Equal(t, []time.Duration{time.Minute, 2 * time.Hour}, v.value)
+
+	v = NewDurationSliceValue([]time.Duration{time.Second})
+	assert.NoError(t, v.Set(""))
+	assert.Equal(t, []time.Duration{}, v.value)
+}
@@ -0,0 +1,177 @@
+package asp
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// configLayers returns the config files to merge, in merge order.  For
+// layered config, that's every default config file that exists (lowest
+// precedence first), and then each `--config` file; otherwise, it's the single
+// config file viper would have read.  Each file is followed by its drop-in
+// fragments, if there are any.  The drop-in directories that were checked
+// (whether they exist or not) are also returned.
+func (a *aspBase) configLayers() ([]string, []string, error) {
+	files := []string{}
+
+	switch {
+	case a.layered:
+		files = append(a.findDefaultConfigFiles(), a.cfgFiles...)
+
+	case a.withConfigFlag && a.cfgFile != "":
+		files = append(files, a.cfgFile)
+
+	default:
+		if file := a.findDefaultConfigFile(); file != "" {
+			files = append(files, file)
+		}
+	}
+
+	if a.dropInDir == "" {
+		return files, nil, nil
+	}
+
+	layers := []string{}
+	dirs := []string{}
+	seen := map[string]bool{}
+
+	for _, file := range files {
+		layers = append(layers, file)
+
+		dir := a.dropInDir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(file), dir)
+		}
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+
+		fragments, err := dropInFiles(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, fragments...)
+	}
+
+	return layers, dirs, nil
+}
+
+// dropInFiles returns the config files (with any extension viper supports) in
+// the drop-in directory, in lexical order.  A missing directory simply has no
+// files.
+func dropInFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, errors.WithMessagef(err, "reading drop-in directory %q", dir)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isConfigFile(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+
+	return files, nil
+}
+
+// isConfigFile reports whether the file has one of the extensions viper
+// supports.
+func isConfigFile(file string) bool {
+	return slices.Contains(viper.SupportedExts, strings.TrimPrefix(filepath.Ext(file), "."))
+}
+
+// readMergedConfig reads each of the config layers, merges them (and then the
+// selected profile, if any), and loads the result into viper.  The whole
+// merged config is loaded every time (even if it's empty), so that nothing
+// lingers from a layer that's gone away.
+func (a *aspBase) readMergedConfig() error {
+	files, dropInDirs, err := a.configLayers()
+	if err != nil {
+		return err
+	}
+
+	merged := map[string]any{}
+
+	for _, file := range files {
+		settings, err := readConfigFile(file)
+		if err != nil {
+			return err
+		}
+		mergeSettings(merged, settings)
+	}
+
+	if a.profiles {
+		merged, err = a.applyProfile(merged)
+		if err != nil {
+			return err
+		}
+	}
+
+	b, err := yaml.Marshal(merged)
+	if err != nil {
+		return err
+	}
+
+	a.vip.SetConfigType("yaml")
+	err = a.vip.ReadConfig(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	a.filesRead = files
+	a.dropInDirsRead = dropInDirs
+	return nil
+}
+
+// readConfigFile reads a single config file (in any format viper supports)
+// into a nested map with lower-cased keys.
+func readConfigFile(file string) (map[string]any, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+
+	err := v.ReadInConfig()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "reading config file %q", file)
+	}
+
+	return v.AllSettings(), nil
+}
+
+// mergeSettings deep-merges src into dst: maps are merged key by key (at every
+// level), and everything else (including lists) from src replaces the value
+// in dst.
+func mergeSettings(dst map[string]any, src map[string]any) {
+	for key, srcVal := range src {
+		srcMap, srcIsMap := srcVal.(map[string]any)
+		dstMap, dstIsMap := dst[key].(map[string]any)
+
+		if srcIsMap && dstIsMap {
+			mergeSettings(dstMap, srcMap)
+			continue
+		}
+
+		if srcIsMap {
+			// copy, so that later merges don't change the source
+			copied := map[string]any{}
+			mergeSettings(copied, srcMap)
+			srcVal = copied
+		}
+
+		dst[key] = srcVal
+	}
+}
@@ -0,0 +1,272 @@
+package asp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type layersTestConfig struct {
+	Host   string
+	Port   int
+	Tags   []string
+	Labels map[string]string
+	Nested struct {
+		Enabled bool
+		Level   string
+	}
+	Databases map[string]database
+}
+
+func writeLayer(t *testing.T, file string, content string) string {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(file), 0o700)
+	assert.NoError(t, err)
+	err = os.WriteFile(file, []byte(content), 0o600)
+	assert.NoError(t, err)
+
+	return file
+}
+
+func TestLayeredConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	work := t.TempDir()
+	t.Chdir(work)
+
+	name := "asp-layered-test"
+
+	// lowest precedence first...
+	homeFile := writeLayer(t, filepath.Join(home, name+".toml"), `port = 2`)
+	homeConfig := writeLayer(t, filepath.Join(home, ".config", name+".yaml"), `host: home-config
+port: 1
+tags: [a, b]
+labels:
+  team: core
+  env: dev
+nested:
+  enabled: true
+databases:
+  primary:
+    host: db1
+    port: 5432
+`)
+	workFile := writeLayer(t, filepath.Join(work, name+".json"), `{
+  "tags": ["c"],
+  "labels": {"env": "prod"},
+  "nested": {"level": "debug"},
+  "databases": {"primary": {"host": "db2"}, "replica": {"host": "db3"}}
+}`)
+	extra := writeLayer(t, filepath.Join(t.TempDir(), "extra.yaml"), "host: extra\n")
+	last := writeLayer(t, filepath.Join(t.TempDir(), "last.yaml"), "port: 3\n")
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, layersTestConfig{Host: "default"},
+		WithDefaultConfigName(name), WithLayeredConfig)
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", extra, "--config", last})
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+
+	expected := layersTestConfig{
+		Host:   "extra",
+		Port:   3,
+		Tags:   []string{"c"},
+		Labels: map[string]string{"team": "core", "env": "prod"},
+		Databases: map[string]database{
+			"primary": {Host: "db2", Port: 5432},
+			"replica": {Host: "db3"},
+		},
+	}
+	expected.Nested.Enabled = true
+	expected.Nested.Level = "debug"
+	assert.Equal(t, &expected, cfg)
+
+	assert.Equal(t, []string{homeFile, homeConfig, workFile, extra, last}, a.ConfigFiles())
+
+	// every file's contribution is kept
+	provenance, err := a.Provenance()
+	assert.NoError(t, err)
+	for _, p := range provenance {
+		if p.Key != "port" {
+			continue
+		}
+		assert.Equal(t, Candidate{Source: SourceConfig, Name: last, Value: "3", Line: 1}, *p.Winner)
+		assert.Equal(t, []Candidate{
+			{Source: SourceConfig, Name: homeConfig, Value: "1", Line: 2},
+			{Source: SourceConfig, Name: homeFile, Value: "2"},
+			{Source: SourceDefault, Value: "0"},
+		}, p.Overridden)
+	}
+
+	// nothing lingers from a layer that's gone away
+	err = os.Remove(workFile)
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, cfg.Tags)
+	assert.Equal(t, "", cfg.Nested.Level)
+	assert.Len(t, a.ConfigFiles(), 4)
+}
+
+func TestLayeredConfigErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// a missing --config file is an error
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, layersTestConfig{}, WithLayeredConfig)
+	assert.NoError(t, err)
+
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	err = cmd.ParseFlags([]string{"--config", missing})
+	assert.NoError(t, err)
+
+	_, err = a.Config()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorContains(t, err, missing)
+
+	// ... as is a bad one, which is named
+	bad := writeLayer(t, filepath.Join(t.TempDir(), "bad.yaml"), "port: [\n")
+
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, layersTestConfig{}, WithLayeredConfig)
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", bad})
+	assert.NoError(t, err)
+
+	_, err = a.Config()
+	assert.ErrorContains(t, err, bad)
+
+	// no files at all is fine
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, layersTestConfig{Port: 1}, WithLayeredConfig)
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cfg.Port)
+	assert.Empty(t, a.ConfigFiles())
+}
+
+func TestMergeSettings(t *testing.T) {
+	src := map[string]any{
+		"scalar": 2,
+		"list":   []any{"c"},
+		"map":    map[string]any{"b": 2, "nested": map[string]any{"y": 2}},
+		"new":    map[string]any{"z": 1},
+	}
+	dst := map[string]any{
+		"scalar": 1,
+		"list":   []any{"a", "b"},
+		"map":    map[string]any{"a": 1, "nested": map[string]any{"x": 1}},
+		"keep":   true,
+	}
+
+	mergeSettings(dst, src)
+
+	assert.Equal(t, map[string]any{
+		"scalar": 2,
+		"list":   []any{"c"},
+		"map":    map[string]any{"a": 1, "b": 2, "nested": map[string]any{"x": 1, "y": 2}},
+		"new":    map[string]any{"z": 1},
+		"keep":   true,
+	}, dst)
+
+	// merging into the copy doesn't change the source
+	mergeSettings(dst, map[string]any{"new": map[string]any{"w": 1}})
+	assert.Equal(t, map[string]any{"z": 1}, src["new"])
+}
+
+func TestDropInDir(t *testing.T) {
+	dir := t.TempDir()
+	mainFile := writeLayer(t, filepath.Join(dir, "app.toml"), `host = "main"
+port = 1
+tags = ["a"]
+
+[labels]
+team = "core"
+`)
+	confd := filepath.Join(dir, "conf.d")
+	second := writeLayer(t, filepath.Join(confd, "20-port.json"), `{"port": 3, "labels": {"env": "prod"}}`)
+	first := writeLayer(t, filepath.Join(confd, "10-port.yaml"), "port: 2\ntags: [b]\n")
+	writeLayer(t, filepath.Join(confd, "README.md"), "# not config\n")
+	writeLayer(t, filepath.Join(confd, "nested", "30-ignored.yaml"), "port: 4\n")
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, layersTestConfig{}, WithDropInDir("conf.d"))
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", mainFile})
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "main", cfg.Host)
+	assert.Equal(t, 3, cfg.Port)
+	assert.Equal(t, []string{"b"}, cfg.Tags)
+	assert.Equal(t, map[string]string{"team": "core", "env": "prod"}, cfg.Labels)
+	assert.Equal(t, []string{mainFile, first, second}, a.ConfigFiles())
+
+	// a bad fragment is named
+	bad := writeLayer(t, filepath.Join(confd, "15-bad.yaml"), "port: [\n")
+
+	_, err = a.Config()
+	assert.ErrorContains(t, err, bad)
+
+	// no drop-in directory is fine
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, layersTestConfig{}, WithDropInDir("missing.d"))
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", mainFile})
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cfg.Port)
+	assert.Equal(t, []string{mainFile}, a.ConfigFiles())
+}
+
+func TestDropInDirSearch(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	work := t.TempDir()
+	t.Chdir(work)
+
+	name := "asp-dropin-test"
+
+	homeFile := writeLayer(t, filepath.Join(home, name+".yaml"), "host: home\nport: 1\n")
+	homeFragment := writeLayer(t, filepath.Join(home, name+".d", "port.yaml"), "port: 2\n")
+	workFile := writeLayer(t, filepath.Join(work, name+".yaml"), "host: work\n")
+
+	// only the file that's found gets its fragments...
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, layersTestConfig{}, WithDefaultConfigName(name), WithDropInDir(name+".d"))
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "work", cfg.Host)
+	assert.Equal(t, 0, cfg.Port)
+	assert.Equal(t, []string{workFile}, a.ConfigFiles())
+
+	// ... but with layered config, each one does
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, layersTestConfig{}, WithDefaultConfigName(name), WithDropInDir(name+".d"), WithLayeredConfig)
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "work", cfg.Host)
+	assert.Equal(t, 2, cfg.Port)
+	assert.Equal(t, []string{homeFile, homeFragment, workFile}, a.ConfigFiles())
+}
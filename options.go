@@ -50,3 +50,92 @@ func WithDecodeHook(decodeHook mapstructure.DecodeHookFunc) Option {
 		return nil
 	}
 }
+
+// WithStrictConfig makes [Asp.Config] fail with an [UnknownKeysError] if the
+// config file has any keys that don't match a config field (like "time_out"
+// instead of "timeout"), rather than silently ignoring them.  Use
+// [WithWarningHandler] to report them without failing.
+func WithStrictConfig(a *aspBase) error {
+	a.strictConfig = true
+	return nil
+}
+
+// WithStrictEnv makes [Asp.Config] fail with an [UnknownEnvError] if there
+// are any environment variables that start with the env prefix (see
+// [WithEnvPrefix]) but don't match a config field (like "APP_DATABSE_HOST"
+// instead of "APP_DATABASE_HOST"), rather than silently ignoring them.  Use
+// [WithWarningHandler] to report them without failing.
+func WithStrictEnv(a *aspBase) error {
+	a.strictEnv = true
+	return nil
+}
+
+// WithWarningHandler reports the problems found by strict checking (like
+// [WithStrictConfig] or [WithStrictEnv]) to the given handler, rather than
+// failing [Asp.Config].
+func WithWarningHandler(handler func(err error)) Option {
+	return func(a *aspBase) error {
+		a.warningHandler = handler
+		return nil
+	}
+}
+
+// WithConfigPaths replaces the directories searched for the default config
+// file (see [WithDefaultConfigName]), which are given in order of precedence.
+// Environment variables (like `$HOME`) in the paths are expanded.  Without
+// this option, asp searches ".", "$HOME/.config", "$HOME", and "/etc".
+func WithConfigPaths(paths ...string) Option {
+	return func(a *aspBase) error {
+		a.configPaths = append([]string{}, paths...)
+		return nil
+	}
+}
+
+// WithXDGConfig searches for the default config file in the locations the XDG
+// Base Directory spec calls for, rather than the default search paths:
+// `$XDG_CONFIG_HOME/<name>/config.*` (where `$XDG_CONFIG_HOME` defaults to
+// `$HOME/.config`), and then `<dir>/<name>/config.*` for each dir in
+// `$XDG_CONFIG_DIRS` (which defaults to `/etc/xdg`).  Any [WithConfigPaths]
+// directories are searched first.
+func WithXDGConfig(a *aspBase) error {
+	a.xdgConfig = true
+	return nil
+}
+
+// WithLayeredConfig reads *every* config file, rather than just one, and
+// deep-merges them: the default config file (see [WithDefaultConfigName]) from
+// each of the search directories, from `/etc` up to the current directory, and
+// then each `--config` file, which may be given more than once.  Later files
+// override earlier ones; maps are merged key by key, but anything else
+// (including a list) replaces the earlier value entirely.
+func WithLayeredConfig(a *aspBase) error {
+	a.layered = true
+	return nil
+}
+
+// WithDropInDir merges every config file in a "drop-in" directory (like
+// `conf.d`) over the config file it sits next to.  The fragments are merged in
+// lexical order (so `10-base.yaml` comes before `20-override.toml`), may be in
+// any format viper supports, and follow the same merge rules as
+// [WithLayeredConfig].  A relative dir is relative to the config file's
+// directory, and the fragments are only loaded when there's a config file.
+func WithDropInDir(dir string) Option {
+	return func(a *aspBase) error {
+		a.dropInDir = dir
+		return nil
+	}
+}
+
+// WithProfiles lets one config file hold several named profiles (like "dev",
+// "staging", and "prod"), each in a top-level section of its own; any
+// top-level section that isn't a config field is a profile.  The profile is
+// selected with a `--profile` flag or the `APP_PROFILE` environment variable
+// (using the env prefix), and its section is merged over the rest of the file
+// (with the same rules as [WithLayeredConfig]), before environment variables
+// and flags are applied.  [Asp.Profiles] lists the available profiles.  With
+// [WithStrictConfig], a profile whose name is close to a config key is
+// reported as a likely misspelling.
+func WithProfiles(a *aspBase) error {
+	a.profiles = true
+	return nil
+}
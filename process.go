@@ -1,8 +1,12 @@
 package asp
 
 import (
+	"encoding"
 	"fmt"
 	"log"
+	"net"
+	"net/netip"
+	"net/url"
 	"reflect"
 	"regexp"
 	"strings"
@@ -10,8 +14,10 @@ import (
 	"time"
 
 	"github.com/Masterminds/sprig/v3"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/iancoleman/strcase"
 	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
 
 	"github.com/jaredreisinger/asp/decoders"
 )
@@ -23,7 +29,7 @@ var (
 
 	// ErrConfigFieldUnsupported is returned when a member of the config struct
 	// is an unsupported type.
-	ErrConfigFieldUnsupported = errors.New("config struct field is of an unsupported type (pointer, array, channel or size-specific number)")
+	ErrConfigFieldUnsupported = errors.New("config struct field is of an unsupported type (array, channel, complex number, etc.)")
 )
 
 // TODO: with the introduction of sprig, we may not need these...
@@ -100,37 +106,9 @@ func (a *aspBase) processStructInner(s interface{}, parentAttrs attrs) error {
 
 		joinedAttrs := parentAttrs.join(childAttrs)
 
-		// Special handling for the description: if neither {{.Env}} or
-		// {{.NoEnv}} appears in the string, we append a "(env: {{Env}})"
-		// suffix. Unless, of course, the description has been explicitly
-		// omitted (but why, oh why, would you do that?)
-		desc := joinedAttrs.desc
-		if desc != "" {
-			re := regexp.MustCompile(`\{\{\w*\.(?:No)?Env\w*`)
-			if !re.Match([]byte(desc)) {
-				desc = fmt.Sprintf("%s (env: {{.Env}})", desc)
-			}
-
-			// We allow the description attribute to include template values
-			// that we fill in based on the calculated name, env, etc.
-			tmpl, err := template.New("desc").Funcs(sprig.TxtFuncMap()).Funcs(templateFuncs).Parse(desc)
-			if err != nil {
-				return errors.WithMessagef(err, "on %s (%q)", f.Name, desc)
-			}
-			descBuilder := &strings.Builder{}
-			err = tmpl.Execute(descBuilder, map[string]string{
-				"Name":  joinedAttrs.name,
-				"Long":  joinedAttrs.long,
-				"Short": joinedAttrs.short,
-				"Env":   joinedAttrs.env,
-				"NoEnv": "",
-				// special context naming (from ASE work)
-				"ParentName": parentAttrs.name,
-			})
-			if err != nil {
-				return err
-			}
-			desc = descBuilder.String()
+		desc, err := describe(f.Name, joinedAttrs, parentAttrs)
+		if err != nil {
+			return err
 		}
 
 		// use shortened names purely for concision...
@@ -157,6 +135,37 @@ func (a *aspBase) processStructInner(s interface{}, parentAttrs attrs) error {
 		// flags.Value-creating helpers, and we need those.
 
 		fieldVal := structVal.FieldByIndex(f.Index)
+		fieldType := f.Type
+
+		// Pointer fields are "optional" settings: they are left nil unless a
+		// flag, environment variable, config file (or non-nil default)
+		// supplies a value.  We process them as if they were the pointed-to
+		// type, but remember the key so that [asp.Config] can tell the
+		// difference.
+		if fieldType.Kind() == reflect.Pointer {
+			a.optionalKeys = append(a.optionalKeys, strings.ToLower(joinedAttrs.name))
+			fieldType = fieldType.Elem()
+
+			if fieldVal.IsNil() {
+				fieldVal = reflect.Zero(fieldType)
+				joinedAttrs.optional = true
+			} else {
+				fieldVal = fieldVal.Elem()
+			}
+		}
+
+		// An `asp.default` tag only applies if the passed-in default is the
+		// zero value; it's parsed exactly like an environment variable would
+		// be.  (A nil pointer with a tag default is no longer "optional".)
+		if joinedAttrs.def != "" && fieldVal.IsZero() {
+			defVal, err := a.decodeDefault(joinedAttrs.def, fieldType)
+			if err != nil {
+				return errors.WithMessagef(err, "default for %s (%q)", f.Name, joinedAttrs.def)
+			}
+			fieldVal = defVal
+			joinedAttrs.optional = false
+		}
+
 		intf := fieldVal.Interface()
 
 		// switch it := intf.(type) {
@@ -175,7 +184,7 @@ func (a *aspBase) processStructInner(s interface{}, parentAttrs attrs) error {
 			flags.DurationP(l, s, val, d)
 
 		case []time.Duration:
-			flags.DurationSliceP(l, s, val, d)
+			flags.VarP(decoders.NewDurationSliceValue(val), l, s, d)
 
 		case bool:
 			flags.BoolP(l, s, val, d)
@@ -186,19 +195,76 @@ func (a *aspBase) processStructInner(s interface{}, parentAttrs attrs) error {
 		case uint:
 			flags.UintP(l, s, val, d)
 
+		case int8:
+			flags.Int8P(l, s, val, d)
+
+		case int16:
+			flags.Int16P(l, s, val, d)
+
+		case int32:
+			flags.Int32P(l, s, val, d)
+
+		case int64:
+			flags.Int64P(l, s, val, d)
+
+		case uint8:
+			flags.Uint8P(l, s, val, d)
+
+		case uint16:
+			flags.Uint16P(l, s, val, d)
+
+		case uint32:
+			flags.Uint32P(l, s, val, d)
+
+		case uint64:
+			flags.Uint64P(l, s, val, d)
+
+		case float32:
+			flags.Float32P(l, s, val, d)
+
+		case float64:
+			flags.Float64P(l, s, val, d)
+
 		case string:
-			// FUTURE: should we handle "rich" parsing for things like IP
-			// addresses, Durations, etc?
 			flags.StringP(l, s, val, d)
 
 		case []bool:
 			flags.BoolSliceP(l, s, val, d)
 
+		// pflag only has some of the number slices, and its slices can't be
+		// set to empty, so we use our own for all of them...
 		case []int:
-			flags.IntSliceP(l, s, val, d)
+			flags.VarP(decoders.NewIntSliceValue(val), l, s, d)
 
 		case []uint:
-			flags.UintSliceP(l, s, val, d)
+			flags.VarP(decoders.NewUintSliceValue(val), l, s, d)
+
+		case []int8:
+			flags.VarP(decoders.NewIntSliceValue(val), l, s, d)
+
+		case []int16:
+			flags.VarP(decoders.NewIntSliceValue(val), l, s, d)
+
+		case []int32:
+			flags.VarP(decoders.NewIntSliceValue(val), l, s, d)
+
+		case []int64:
+			flags.VarP(decoders.NewIntSliceValue(val), l, s, d)
+
+		case []uint16:
+			flags.VarP(decoders.NewUintSliceValue(val), l, s, d)
+
+		case []uint32:
+			flags.VarP(decoders.NewUintSliceValue(val), l, s, d)
+
+		case []uint64:
+			flags.VarP(decoders.NewUintSliceValue(val), l, s, d)
+
+		case []float32:
+			flags.VarP(decoders.NewFloatSliceValue(val), l, s, d)
+
+		case []float64:
+			flags.VarP(decoders.NewFloatSliceValue(val), l, s, d)
 
 		// pFlags supports []byte, but the parsing gets confused?
 		// maybe that's viper?
@@ -211,19 +277,78 @@ func (a *aspBase) processStructInner(s interface{}, parentAttrs attrs) error {
 		case []string:
 			flags.StringSliceP(l, s, val, d)
 
+		// pflag's IP and map values can't be set to empty, so we wrap them so
+		// that they can.
+		case net.IP:
+			ip := val
+			flags.IPVarP(&ip, l, s, val, d)
+			allowEmpty(flags, l, func() { ip = nil })
+
+		case []net.IP:
+			flags.IPSliceP(l, s, val, d)
+
+		case net.IPNet:
+			ipNet := val
+			flags.IPNetVarP(&ipNet, l, s, val, d)
+			allowEmpty(flags, l, func() { ipNet = net.IPNet{} })
+
+		case []net.IPNet:
+			flags.IPNetSliceP(l, s, val, d)
+
+		case netip.Addr:
+			flags.VarP(decoders.NewTextValue(&val), l, s, d)
+
+		case []netip.Addr:
+			flags.VarP(decoders.NewTextSliceValue(val), l, s, d)
+
+		case netip.Prefix:
+			flags.VarP(decoders.NewTextValue(&val), l, s, d)
+
+		case []netip.Prefix:
+			flags.VarP(decoders.NewTextSliceValue(val), l, s, d)
+
+		case netip.AddrPort:
+			flags.VarP(decoders.NewTextValue(&val), l, s, d)
+
+		case []netip.AddrPort:
+			flags.VarP(decoders.NewTextSliceValue(val), l, s, d)
+
+		case url.URL:
+			flags.VarP(decoders.NewURLValue(val), l, s, d)
+
+		case []url.URL:
+			flags.VarP(decoders.NewURLSliceValue(val), l, s, d)
+
 		case map[string]int:
-			flags.StringToIntP(l, s, val, d)
+			m := val
+			flags.StringToIntVarP(&m, l, s, val, d)
+			allowEmpty(flags, l, func() { m = map[string]int{} })
 
 		case map[string]string:
-			flags.StringToStringP(l, s, val, d)
+			m := val
+			flags.StringToStringVarP(&m, l, s, val, d)
+			allowEmpty(flags, l, func() { m = map[string]string{} })
 
 		default:
-			if f.Type.Kind() == reflect.Struct {
+			// Before falling back to the "kind", we check to see if the type
+			// knows how to parse itself; any type that implements
+			// [pflag.Value] or [encoding.TextUnmarshaler] (on its pointer) can
+			// be used directly as a flag.  We prefer pflag.Value, since it's
+			// specific to CLI parsing.
+			ptr := reflect.New(fieldType)
+			ptr.Elem().Set(fieldVal)
+
+			if flagValue, ok := ptr.Interface().(pflag.Value); ok {
+				flags.VarP(flagValue, l, s, d)
+			} else if textValue, ok := ptr.Interface().(encoding.TextUnmarshaler); ok {
+				flags.VarP(decoders.NewTextValue(textValue), l, s, d)
+			} else if fieldType.Kind() == reflect.Struct {
 				recursiveAttrs := joinedAttrs
 
 				// need to think about whether
 				if f.Anonymous {
 					recursiveAttrs = parentAttrs
+					recursiveAttrs.optional = joinedAttrs.optional
 				}
 
 				err := a.processStructInner(intf, recursiveAttrs)
@@ -232,6 +357,32 @@ func (a *aspBase) processStructInner(s interface{}, parentAttrs attrs) error {
 				}
 
 				addBindings = false // prevent default flag/config additions!
+			} else if isCollection(fieldType) {
+				// Slices and maps of structs ("collections") can't be bound in
+				// viper beyond the default value; the flag and environment
+				// variables are layered on by [aspBase.settings].
+				c := &collection{
+					attrs:  joinedAttrs,
+					leaves: collectLeaves(fieldType.Elem(), attrs{}),
+					keyed:  fieldType.Kind() == reflect.Map,
+				}
+				c.flag = &collectionValue{c: c}
+				a.collections = append(a.collections, c)
+				a.fields = append(a.fields, &field{
+					attrs:      joinedAttrs,
+					collection: c,
+					def:        fmt.Sprint(intf),
+					defVal:     intf,
+					hasDef:     !joinedAttrs.optional,
+				})
+
+				flags.VarP(c.flag, l, s, d)
+
+				if !joinedAttrs.optional {
+					vip.SetDefault(joinedAttrs.name, intf)
+				}
+
+				addBindings = false
 			} else {
 				handled = false
 			}
@@ -243,15 +394,32 @@ func (a *aspBase) processStructInner(s interface{}, parentAttrs attrs) error {
 		}
 
 		if addBindings {
+			// Check the constraint tags now, rather than waiting for the
+			// config to be loaded.
+			_, err := a.parseConstraints(joinedAttrs, fieldType)
+			if err != nil {
+				return errors.WithMessagef(err, "on %s", f.Name)
+			}
+
+			a.fields = append(a.fields, &field{
+				attrs:  joinedAttrs,
+				def:    flags.Lookup(joinedAttrs.long).DefValue,
+				defVal: intf,
+				hasDef: !joinedAttrs.optional,
+			})
+
 			// log.Printf("%q, %v, CLI: %q / %q, env: %q, desc: %q",
 			// 	canonicalName, f.Type.Kind(),
 			// 	attrLong, attrShort, attrEnv, attrDesc)
 
 			// Start pushing into viper?  Note that we're going to need to handle
-			// parent paths pretty quickly!
-			vip.SetDefault(joinedAttrs.name, intf)
+			// parent paths pretty quickly!  Optional (nil pointer) fields do
+			// *not* get a default, or they would always appear to be set.
+			if !joinedAttrs.optional {
+				vip.SetDefault(joinedAttrs.name, intf)
+			}
 
-			err := vip.BindPFlag(joinedAttrs.name, flags.Lookup(joinedAttrs.long))
+			err = vip.BindPFlag(joinedAttrs.name, flags.Lookup(joinedAttrs.long))
 			if err != nil {
 				return err
 			}
@@ -267,3 +435,70 @@ func (a *aspBase) processStructInner(s interface{}, parentAttrs attrs) error {
 
 	return nil
 }
+
+// allowEmpty wraps an already-added flag's value so that it can be set to
+// empty (with reset) by an empty string.
+func allowEmpty(flags *pflag.FlagSet, name string, reset func()) {
+	flag := flags.Lookup(name)
+	flag.Value = decoders.NewEmptyValue(flag.Value, reset)
+}
+
+// describe renders the description for a field, filling in any template
+// values.  If neither {{.Env}} or {{.NoEnv}} appears in the string, we append
+// a "(env: {{Env}})" suffix.  Unless, of course, the description has been
+// explicitly omitted (but why, oh why, would you do that?)
+func describe(fieldName string, joinedAttrs attrs, parentAttrs attrs) (string, error) {
+	desc := joinedAttrs.desc
+	if desc == "" {
+		return "", nil
+	}
+
+	re := regexp.MustCompile(`\{\{\w*\.(?:No)?Env\w*`)
+	if !re.Match([]byte(desc)) {
+		desc = fmt.Sprintf("%s (env: {{.Env}})", desc)
+	}
+
+	// We allow the description attribute to include template values that we
+	// fill in based on the calculated name, env, etc.
+	tmpl, err := template.New("desc").Funcs(sprig.TxtFuncMap()).Funcs(templateFuncs).Parse(desc)
+	if err != nil {
+		return "", errors.WithMessagef(err, "on %s (%q)", fieldName, desc)
+	}
+	descBuilder := &strings.Builder{}
+	err = tmpl.Execute(descBuilder, map[string]string{
+		"Name":  joinedAttrs.name,
+		"Long":  joinedAttrs.long,
+		"Short": joinedAttrs.short,
+		"Env":   joinedAttrs.env,
+		"NoEnv": "",
+		// special context naming (from ASE work)
+		"ParentName": parentAttrs.name,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return descBuilder.String(), nil
+}
+
+// decodeDefault parses an `asp.default` tag value into the given type, using
+// the same decode hook as [asp.Config].
+func (a *aspBase) decodeDefault(s string, t reflect.Type) (reflect.Value, error) {
+	ptr := reflect.New(t)
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		DecodeHook:       a.decodeHook,
+		Result:           ptr.Interface(),
+	})
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	err = decoder.Decode(s)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	return ptr.Elem(), nil
+}
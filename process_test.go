@@ -1,7 +1,14 @@
 package asp
 
 import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,6 +36,130 @@ type processTestConfig struct {
 	AnonymousEmbedded
 }
 
+type processSizedTestConfig struct {
+	Int8         int8
+	Int16        int16
+	Int32        int32
+	Int64        int64
+	Uint8        uint8
+	Uint16       uint16
+	Uint32       uint32
+	Uint64       uint64
+	Float32      float32
+	Float64      float64
+	Int8Slice    []int8
+	Int16Slice   []int16
+	Int32Slice   []int32
+	Int64Slice   []int64
+	Uint16Slice  []uint16
+	Uint32Slice  []uint32
+	Uint64Slice  []uint64
+	Float32Slice []float32
+	Float64Slice []float64
+}
+
+// regionCode is a TextMarshaler/TextUnmarshaler test type
+type regionCode string
+
+func (r regionCode) MarshalText() ([]byte, error) { return []byte(r), nil }
+
+func (r *regionCode) UnmarshalText(b []byte) error {
+	if len(b) != 2 {
+		return fmt.Errorf("invalid region %q", b)
+	}
+	*r = regionCode(strings.ToUpper(string(b)))
+	return nil
+}
+
+// hostPort is a pflag.Value test type
+type hostPort struct {
+	Host string
+	Port int
+}
+
+func (h *hostPort) Set(s string) error {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return err
+	}
+	h.Host = host
+	h.Port, err = strconv.Atoi(port)
+	return err
+}
+
+func (h *hostPort) String() string { return net.JoinHostPort(h.Host, strconv.Itoa(h.Port)) }
+func (h *hostPort) Type() string   { return "hostPort" }
+
+type processCustomTestConfig struct {
+	Level    slog.Level
+	Region   regionCode
+	Upstream hostPort
+	Optional *regionCode
+}
+
+type processNetworkTestConfig struct {
+	IP        net.IP
+	IPs       []net.IP
+	IPNet     net.IPNet
+	IPNets    []net.IPNet
+	Addr      netip.Addr
+	Addrs     []netip.Addr
+	Prefix    netip.Prefix
+	Prefixes  []netip.Prefix
+	AddrPort  netip.AddrPort
+	AddrPorts []netip.AddrPort
+	URL       url.URL
+	URLs      []url.URL
+	Upstream  *url.URL
+}
+
+type processOptionalTestConfig struct {
+	Int       *int
+	String    *string
+	Bool      *bool
+	Duration  *time.Duration
+	Nested    *Nested
+	NilNested *Nested
+}
+
+type upstream struct {
+	Host   string
+	Weight int
+	Token  string `asp.sensitive:"true"`
+	TLS    struct {
+		Enabled bool
+	}
+}
+
+type processCollectionTestConfig struct {
+	Upstreams []upstream
+	Nested    struct {
+		Backends []Nested
+	}
+}
+
+type database struct {
+	Host     string
+	Port     int
+	Password string `asp.sensitive:"true"`
+}
+
+type processKeyedTestConfig struct {
+	Databases map[string]database
+}
+
+type processTagDefaultsTestConfig struct {
+	Int      int           `asp.default:"42"`
+	String   string        `asp.default:"hello"`
+	Duration time.Duration `asp.default:"30s"`
+	Strings  []string      `asp.default:"a,b"`
+	Level    slog.Level    `asp.default:"warn"`
+	Optional *int          `asp.default:"7"`
+	Nested   struct {
+		Deep bool `asp.default:"true"`
+	}
+}
+
 type Nested struct {
 	Dummy int
 }
@@ -80,6 +211,264 @@ func TestProcessStruct(t *testing.T) {
 	}
 }
 
+func TestProcessStructSized(t *testing.T) {
+	a := newBase(t)
+
+	err := a.processStruct(processSizedTestConfig{})
+	assert.NoError(t, err)
+
+	// flag name and type (note that strcase treats the size as a separate
+	// "word")
+	cases := map[string]string{
+		"int-8":          "int8",
+		"int-16":         "int16",
+		"int-32":         "int32",
+		"int-64":         "int64",
+		"uint-8":         "uint8",
+		"uint-16":        "uint16",
+		"uint-32":        "uint32",
+		"uint-64":        "uint64",
+		"float-32":       "float32",
+		"float-64":       "float64",
+		"int-8-slice":    "int8Slice",
+		"int-16-slice":   "int16Slice",
+		"int-32-slice":   "int32Slice",
+		"int-64-slice":   "int64Slice",
+		"uint-16-slice":  "uint16Slice",
+		"uint-32-slice":  "uint32Slice",
+		"uint-64-slice":  "uint64Slice",
+		"float-32-slice": "float32Slice",
+		"float-64-slice": "float64Slice",
+	}
+	for n, typ := range cases {
+		f := a.cmd.PersistentFlags().Lookup(n)
+		if assert.NotNil(t, f, n) {
+			assert.Equal(t, typ, f.Value.Type(), n)
+		}
+	}
+}
+
+func TestProcessStructCustom(t *testing.T) {
+	a := newBase(t)
+
+	err := a.processStruct(processCustomTestConfig{
+		Level:    slog.LevelWarn,
+		Upstream: hostPort{"localhost", 80},
+	})
+	assert.NoError(t, err)
+
+	// flag name, type and default
+	cases := map[string][2]string{
+		"level":    {"level", "WARN"},
+		"region":   {"regionCode", ""},
+		"upstream": {"hostPort", "localhost:80"},
+		"optional": {"regionCode", ""},
+	}
+	for n, v := range cases {
+		f := a.cmd.PersistentFlags().Lookup(n)
+		if assert.NotNil(t, f, n) {
+			assert.Equal(t, v[0], f.Value.Type(), n)
+			assert.Equal(t, v[1], f.DefValue, n)
+		}
+	}
+
+	// the nested-looking struct must *not* have been recursed into
+	assert.Nil(t, a.cmd.PersistentFlags().Lookup("upstream-host"))
+}
+
+func TestProcessStructNetwork(t *testing.T) {
+	a := newBase(t)
+
+	err := a.processStruct(processNetworkTestConfig{
+		Prefix: netip.MustParsePrefix("10.0.0.0/8"),
+	})
+	assert.NoError(t, err)
+
+	// flag name, type and default
+	cases := map[string][2]string{
+		"ip":         {"ip", "<nil>"},
+		"i-ps":       {"ipSlice", "[]"},
+		"ip-net":     {"ipNet", "<nil>"},
+		"ip-nets":    {"ipNetSlice", "[]"},
+		"addr":       {"addr", ""},
+		"addrs":      {"addrSlice", "[]"},
+		"prefix":     {"prefix", "10.0.0.0/8"},
+		"prefixes":   {"prefixSlice", "[]"},
+		"addr-port":  {"addrPort", ""},
+		"addr-ports": {"addrPortSlice", "[]"},
+		"url":        {"url", ""},
+		"ur-ls":      {"urlSlice", "[]"},
+		"upstream":   {"url", ""},
+	}
+	for n, v := range cases {
+		f := a.cmd.PersistentFlags().Lookup(n)
+		if assert.NotNil(t, f, n) {
+			assert.Equal(t, v[0], f.Value.Type(), n)
+			assert.Equal(t, v[1], f.DefValue, n)
+		}
+	}
+}
+
+func TestProcessStructOptional(t *testing.T) {
+	a := newBase(t)
+
+	err := a.processStruct(processOptionalTestConfig{
+		Nested: &Nested{Dummy: 3},
+	})
+	assert.NoError(t, err)
+
+	names := []string{
+		"int",
+		"string",
+		"bool",
+		"duration",
+		"nested-dummy",
+		"nil-nested-dummy",
+	}
+	for _, n := range names {
+		f := a.cmd.PersistentFlags().Lookup(n)
+		assert.NotNil(t, f, n)
+	}
+
+	assert.Equal(t, []string{"int", "string", "bool", "duration", "nested", "nilnested"}, a.optionalKeys)
+
+	// only the non-nil pointer gets a default...
+	assert.True(t, a.vip.IsSet("nested.dummy"))
+	assert.False(t, a.vip.IsSet("nilnested.dummy"))
+	assert.False(t, a.vip.IsSet("int"))
+}
+
+func TestProcessStructCollection(t *testing.T) {
+	a := newBase(t)
+	a.envPrefix = "APP"
+
+	err := a.processStruct(processCollectionTestConfig{
+		Upstreams: []upstream{{Host: "default"}},
+	})
+	assert.NoError(t, err)
+
+	for _, n := range []string{"upstreams", "nested-backends"} {
+		f := a.cmd.PersistentFlags().Lookup(n)
+		if assert.NotNil(t, f, n) {
+			assert.Equal(t, "fields", f.Value.Type(), n)
+		}
+	}
+
+	if assert.Len(t, a.collections, 2) {
+		c := a.collections[0]
+		assert.Equal(t, "upstreams", c.key())
+		assert.Equal(t, []string{"host", "weight", "token", "tls-enabled"}, mapSlice(c.leaves, func(l attrs) string { return l.long }))
+		assert.Equal(t, []string{"HOST", "WEIGHT", "TOKEN", "TLS_ENABLED"}, mapSlice(c.leaves, func(l attrs) string { return l.env }))
+		assert.Equal(t, "nested.backends", a.collections[1].key())
+	}
+
+	assert.Equal(t, []upstream{{Host: "default"}}, a.vip.Get("upstreams"))
+}
+
+func TestCollectionValue(t *testing.T) {
+	a := newBase(t)
+
+	err := a.processStruct(processCollectionTestConfig{})
+	assert.NoError(t, err)
+
+	v := a.collections[0].flag
+
+	assert.NoError(t, v.Set("host=a,weight=1"))
+	assert.NoError(t, v.Set(`"host=b,c",tls-enabled=true`))
+	assert.Equal(t, []any{
+		map[string]any{"host": "a", "weight": "1"},
+		map[string]any{"host": "b,c", "tls": map[string]any{"enabled": "true"}},
+	}, v.items)
+	assert.Equal(t, `[host=a,weight=1; "host=b,c",tls-enabled=true]`, v.String())
+
+	assert.NoError(t, v.Set(""))
+	assert.Empty(t, v.items)
+
+	assert.ErrorContains(t, v.Set("bogus=1"), `unknown key "bogus"`)
+	assert.ErrorContains(t, v.Set("host"), "expected key=value")
+}
+
+func TestProcessStructKeyedCollection(t *testing.T) {
+	a := newBase(t)
+	a.envPrefix = "APP"
+
+	err := a.processStruct(processKeyedTestConfig{})
+	assert.NoError(t, err)
+
+	f := a.cmd.PersistentFlags().Lookup("databases")
+	if assert.NotNil(t, f) {
+		assert.Equal(t, "fields", f.Value.Type())
+	}
+
+	if assert.Len(t, a.collections, 1) {
+		assert.True(t, a.collections[0].keyed)
+	}
+
+	v := a.collections[0].flag
+	assert.NoError(t, v.Set("primary.host=a,primary.port=1"))
+	assert.NoError(t, v.Set("Primary.port=2,replica.host=b"))
+	assert.Equal(t, map[string]any{
+		"primary": map[string]any{"host": "a", "port": "2"},
+		"replica": map[string]any{"host": "b"},
+	}, v.value())
+
+	assert.ErrorContains(t, v.Set("host=a"), "expected name.key=value")
+	assert.ErrorContains(t, v.Set("primary.bogus=a"), `unknown key "bogus"`)
+}
+
+func TestProcessStructUnsupportedCollections(t *testing.T) {
+	cases := map[string]any{
+		"int keys":   struct{ M map[int]Nested }{},
+		"any values": struct{ M map[string]any }{},
+	}
+
+	for k, v := range cases {
+		t.Run(k, func(t *testing.T) {
+			a := newBase(t)
+			err := a.processStruct(v)
+			assert.ErrorIs(t, err, ErrConfigFieldUnsupported)
+		})
+	}
+}
+
+func TestProcessStructTagDefaults(t *testing.T) {
+	a := newBase(t)
+	a.decodeHook = DefaultDecodeHook
+
+	err := a.processStruct(processTagDefaultsTestConfig{String: "explicit"})
+	assert.NoError(t, err)
+
+	// flag name, default
+	cases := map[string]string{
+		"int":         "42",
+		"string":      "explicit", // the passed-in default wins
+		"duration":    "30s",
+		"strings":     "[a,b]",
+		"level":       "WARN",
+		"optional":    "7",
+		"nested-deep": "true",
+	}
+	for n, v := range cases {
+		f := a.cmd.PersistentFlags().Lookup(n)
+		if assert.NotNil(t, f, n) {
+			assert.Equal(t, v, f.DefValue, n)
+		}
+	}
+
+	assert.Equal(t, 42, a.vip.Get("int"))
+	assert.True(t, a.vip.IsSet("optional"))
+}
+
+func TestProcessStructBadTagDefault(t *testing.T) {
+	a := newBase(t)
+	a.decodeHook = DefaultDecodeHook
+
+	err := a.processStruct(struct {
+		Int int `asp.default:"nope"`
+	}{})
+	assert.ErrorContains(t, err, `default for Int ("nope")`)
+}
+
 func TestProcessStructInnerErrors(t *testing.T) {
 	a := newBase(t)
 
@@ -88,7 +477,7 @@ func TestProcessStructInnerErrors(t *testing.T) {
 	assert.ErrorIs(t, err, ErrConfigMustBeStruct)
 
 	cfgWithBadMember := struct {
-		BadMember *int
+		BadMember chan int
 	}{}
 
 	err = a.processStructInner(cfgWithBadMember, attrs{})
@@ -96,7 +485,7 @@ func TestProcessStructInnerErrors(t *testing.T) {
 
 	cfgWithBadNestedMember := struct {
 		BadNest struct {
-			BadMember *int
+			BadMember chan int
 		}
 	}{}
 
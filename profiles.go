@@ -0,0 +1,96 @@
+package asp
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownProfile indicates that the selected profile (from the `--profile`
+// flag or the `APP_PROFILE` environment variable) isn't one of the profile
+// sections in the config file.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// profileEnv returns the name of the environment variable that selects the
+// profile, like "APP_PROFILE".
+func (a *aspBase) profileEnv() string {
+	prefix := strings.TrimRight(a.envPrefix, "_")
+	if prefix == "" {
+		return "PROFILE"
+	}
+	return prefix + "_PROFILE"
+}
+
+// selectedProfile returns the (lower-cased) profile selected by the
+// `--profile` flag, or failing that, the profile environment variable; "" means
+// no profile is selected.
+func (a *aspBase) selectedProfile() string {
+	profile := a.profile
+	if profile == "" {
+		profile = os.Getenv(a.profileEnv())
+	}
+	return strings.ToLower(profile)
+}
+
+// applyProfile splits the (merged) config file settings into the base section
+// and the profile sections, and then merges the selected profile's section (if
+// any) over the base.  A profile section is any top-level section that isn't
+// a config field.
+func (a *aspBase) applyProfile(settings map[string]any) (map[string]any, error) {
+	known := knownKeys(a.baseType)
+	base := map[string]any{}
+	a.profileSections = map[string]map[string]any{}
+
+	for key, val := range settings {
+		section, isMap := val.(map[string]any)
+		if _, ok := known[key]; ok || !isMap {
+			base[key] = val
+			continue
+		}
+		a.profileSections[key] = section
+	}
+
+	profile := a.selectedProfile()
+	if profile == "" {
+		return base, nil
+	}
+
+	section, ok := a.profileSections[profile]
+	if !ok {
+		available := "none"
+		if names := a.profileNames(); len(names) > 0 {
+			available = strings.Join(names, ", ")
+		}
+		return nil, fmt.Errorf("%w %q (available profiles: %s)", ErrUnknownProfile, profile, available)
+	}
+
+	mergeSettings(base, section)
+	return base, nil
+}
+
+// profileNames returns the names of the profile sections from the most recent
+// load, in order.
+func (a *aspBase) profileNames() []string {
+	return slices.Sorted(maps.Keys(a.profileSections))
+}
+
+func (a *aspBase) Profiles() ([]string, error) {
+	a.vipMu.Lock()
+	defer a.vipMu.Unlock()
+
+	if !a.profiles {
+		return []string{}, nil
+	}
+
+	// The profiles are still listed when the selected one doesn't exist,
+	// since that's exactly when they're needed.
+	err := a.readConfig()
+	if err != nil && !errors.Is(err, ErrUnknownProfile) {
+		return nil, err
+	}
+
+	return a.profileNames(), nil
+}
@@ -0,0 +1,171 @@
+package asp
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+const profilesTestFile = `host: base
+port: 80
+labels:
+  team: core
+dev:
+  host: localhost
+  port: 8080
+  labels:
+    env: dev
+prod:
+  host: example.com
+`
+
+func profilesInstance(t *testing.T, content string, options ...Option) (*cobra.Command, Asp[layersTestConfig], string) {
+	t.Helper()
+
+	file := writeLayer(t, filepath.Join(t.TempDir(), "config.yaml"), content)
+
+	cmd := &cobra.Command{Use: "app"}
+	a, err := AttachInstance(cmd, layersTestConfig{}, append([]Option{WithProfiles}, options...)...)
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", file})
+	assert.NoError(t, err)
+
+	return cmd, a, file
+}
+
+func TestProfiles(t *testing.T) {
+	cmd, a, _ := profilesInstance(t, profilesTestFile)
+
+	// no profile, just the base section
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "base", cfg.Host)
+	assert.Equal(t, 80, cfg.Port)
+	assert.Equal(t, map[string]string{"team": "core"}, cfg.Labels)
+
+	profiles, err := a.Profiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev", "prod"}, profiles)
+
+	// from the environment
+	t.Setenv("APP_PROFILE", "prod")
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", cfg.Host)
+	assert.Equal(t, 80, cfg.Port)
+
+	// the flag wins, and env still overrides the profile
+	t.Setenv("APP_PORT", "9090")
+	err = cmd.ParseFlags([]string{"--profile", "DEV"})
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost", cfg.Host)
+	assert.Equal(t, 9090, cfg.Port)
+	assert.Equal(t, map[string]string{"team": "core", "env": "dev"}, cfg.Labels)
+
+	// an unknown profile is an error, but the profiles can still be listed
+	err = cmd.ParseFlags([]string{"--profile", "staging"})
+	assert.NoError(t, err)
+
+	_, err = a.Config()
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+	assert.ErrorContains(t, err, `"staging" (available profiles: dev, prod)`)
+
+	profiles, err = a.Profiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dev", "prod"}, profiles)
+}
+
+func TestProfilesWithoutOption(t *testing.T) {
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, layersTestConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, cmd.PersistentFlags().Lookup("profile"))
+
+	profiles, err := a.Profiles()
+	assert.NoError(t, err)
+	assert.Empty(t, profiles)
+
+	// a config field can't be named "profile"
+	_, err = AttachInstance(&cobra.Command{}, struct{ Profile string }{}, WithProfiles)
+	assert.ErrorContains(t, err, "--profile")
+}
+
+func TestProfilesProvenance(t *testing.T) {
+	cmd, a, file := profilesInstance(t, profilesTestFile)
+
+	err := cmd.ParseFlags([]string{"--profile", "dev"})
+	assert.NoError(t, err)
+
+	provenance, err := a.Provenance()
+	assert.NoError(t, err)
+
+	for _, p := range provenance {
+		if p.Key != "port" {
+			continue
+		}
+		assert.Equal(t, Candidate{Source: SourceConfig, Name: file, Value: "8080", Line: 7, Profile: "dev"}, *p.Winner)
+		assert.Equal(t, []Candidate{
+			{Source: SourceConfig, Name: file, Value: "80", Line: 2},
+			{Source: SourceDefault, Value: "0"},
+		}, p.Overridden)
+		assert.Equal(t, `config `+file+`:7="8080" (profile dev)`, p.Winner.String())
+	}
+}
+
+func TestProfilesStrict(t *testing.T) {
+	t.Setenv("APP_PROFILE", "dev")
+
+	_, a, _ := profilesInstance(t, profilesTestFile+"  prot: 443\n", WithStrictConfig, WithStrictEnv)
+
+	_, err := a.Config()
+
+	var unknownErr *UnknownKeysError
+	assert.True(t, errors.As(err, &unknownErr))
+	assert.Equal(t, []UnknownKey{
+		{Key: "prod.prot", Suggestion: "prod.port"},
+	}, unknownErr.Unknown)
+
+	// a top-level section that's close to a config key is most likely a
+	// misspelling, rather than a profile
+	_, a, _ = profilesInstance(t, profilesTestFile+"labls:\n  env: test\n", WithStrictConfig)
+
+	_, err = a.Config()
+	assert.True(t, errors.As(err, &unknownErr))
+	assert.Equal(t, []UnknownKey{
+		{Key: "labls", Suggestion: "labels"},
+	}, unknownErr.Unknown)
+
+	// APP_PROFILE itself is known
+	_, a, _ = profilesInstance(t, profilesTestFile, WithStrictConfig, WithStrictEnv)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost", cfg.Host)
+}
+
+func TestProfilesCommand(t *testing.T) {
+	cmd, _, file := profilesInstance(t, profilesTestFile)
+	AddConfigCommand(cmd)
+
+	out := &bytes.Buffer{}
+	cmd.SetOut(out)
+	cmd.SetArgs([]string{"config", "profiles", "--config", file})
+	err := cmd.Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, "dev\nprod\n", out.String())
+
+	out.Reset()
+	cmd.SetArgs([]string{"config", "env"})
+	err = cmd.Execute()
+	assert.NoError(t, err)
+	assert.Regexp(t, `\nAPP_PROFILE +--profile +-\n$`, out.String())
+}
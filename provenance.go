@@ -0,0 +1,243 @@
+package asp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Source identifies where a configuration value came from.
+type Source int
+
+// The sources, in increasing order of precedence.
+const (
+	SourceDefault Source = iota
+	SourceConfig
+	SourceEnv
+	SourceFlag
+)
+
+// String returns the lower-case name of the source, like "env".
+func (s Source) String() string {
+	switch s {
+	case SourceDefault:
+		return "default"
+	case SourceConfig:
+		return "config"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	}
+	return fmt.Sprintf("Source(%d)", int(s))
+}
+
+// Candidate is a value provided by a single source.  Sensitive values are
+// redacted.
+type Candidate struct {
+	Source  Source
+	Name    string // the flag (like "--port"), environment variable, or config file path
+	Value   string
+	Line    int    // the line in the config file, if known (YAML only)
+	Profile string // the config file's profile section, if the value came from one
+}
+
+// String describes the candidate, like `env APP_PORT="8080"`,
+// `config app.yaml:12="8080"`, or `config app.yaml:20="9090" (profile dev)`.
+func (c Candidate) String() string {
+	name := c.Name
+	if c.Line > 0 {
+		name = fmt.Sprintf("%s:%d", name, c.Line)
+	}
+
+	s := fmt.Sprintf("%s %s=%q", c.Source, name, c.Value)
+	if name == "" {
+		s = fmt.Sprintf("%s=%q", c.Source, c.Value)
+	}
+	if c.Profile != "" {
+		s += " (profile " + c.Profile + ")"
+	}
+	return s
+}
+
+// Provenance reports, for a single setting, the source whose value won, and
+// any other candidate values that it overrode.  Winner is nil if no source
+// (not even a default) provided a value, as for a nil pointer field.
+type Provenance struct {
+	Setting
+	Winner     *Candidate
+	Overridden []Candidate
+}
+
+// Provenance reports where each setting's value comes from, in the same order
+// as the fields in the config struct.  Like [Asp.Config], it reads the config
+// file (if any) first.
+func (a *aspBase) Provenance() ([]Provenance, error) {
+	a.vipMu.Lock()
+	defer a.vipMu.Unlock()
+
+	err := a.readConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Viper only exposes the merged values, so we read each config file
+	// (again) on its own, in order of precedence.
+	type layer struct {
+		file  string
+		vip   *viper.Viper
+		lines map[string]int
+	}
+	layers := []layer{}
+
+	for _, file := range slices.Backward(a.filesRead) {
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+
+		cfgVip := viper.New()
+		cfgVip.SetConfigFile(file)
+		err = cfgVip.ReadInConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		layers = append(layers, layer{file: file, vip: cfgVip, lines: yamlLines(file)})
+	}
+
+	// The selected profile's section is merged over the base section of
+	// *every* file, so its values take precedence.
+	profile := ""
+	if a.profiles {
+		profile = a.selectedProfile()
+	}
+
+	provenance := make([]Provenance, 0, len(a.fields))
+
+	for _, f := range a.fields {
+		key := strings.ToLower(f.attrs.name)
+
+		// in order of precedence...
+		candidates := []Candidate{}
+
+		if flag := a.cmd.PersistentFlags().Lookup(f.attrs.long); flag != nil && flag.Changed {
+			candidates = append(candidates, Candidate{
+				Source: SourceFlag,
+				Name:   "--" + f.attrs.long,
+				Value:  flag.Value.String(),
+			})
+		}
+
+		if f.collection != nil {
+			if fromEnv := f.collection.fromEnv(); fromEnv != nil {
+				candidates = append(candidates, Candidate{
+					Source: SourceEnv,
+					Name:   f.attrs.env + "_*",
+					Value:  fmt.Sprint(fromEnv),
+				})
+			}
+		} else if val := os.Getenv(f.attrs.env); val != "" {
+			candidates = append(candidates, Candidate{
+				Source: SourceEnv,
+				Name:   f.attrs.env,
+				Value:  val,
+			})
+		}
+
+		if profile != "" {
+			profileKey := profile + "." + key
+			for _, l := range layers {
+				if l.vip.InConfig(profileKey) {
+					candidates = append(candidates, Candidate{
+						Source:  SourceConfig,
+						Name:    l.file,
+						Value:   fmt.Sprint(l.vip.Get(profileKey)),
+						Line:    l.lines[profileKey],
+						Profile: profile,
+					})
+				}
+			}
+		}
+
+		for _, l := range layers {
+			if l.vip.InConfig(key) {
+				candidates = append(candidates, Candidate{
+					Source: SourceConfig,
+					Name:   l.file,
+					Value:  fmt.Sprint(l.vip.Get(key)),
+					Line:   l.lines[key],
+				})
+			}
+		}
+
+		if f.hasDef {
+			candidates = append(candidates, Candidate{
+				Source: SourceDefault,
+				Value:  f.def,
+			})
+		}
+
+		if f.attrs.sensitive {
+			for i := range candidates {
+				if candidates[i].Value != "" {
+					candidates[i].Value = "[REDACTED]"
+				}
+			}
+		}
+
+		p := Provenance{Setting: f.setting()}
+		if len(candidates) > 0 {
+			p.Winner = &candidates[0]
+			p.Overridden = candidates[1:]
+		}
+
+		provenance = append(provenance, p)
+	}
+
+	return provenance, nil
+}
+
+// yamlLines returns the line number for every (lower-cased, dotted) key in a
+// YAML file; for any other kind of file, or if the file can't be parsed, it
+// returns nil.
+func yamlLines(path string) map[string]int {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		return nil
+	}
+
+	b, err := os.ReadFile(path) // #nosec G304 -- the file viper just read
+	if err != nil {
+		return nil
+	}
+
+	doc := yaml.Node{}
+	err = yaml.Unmarshal(b, &doc)
+	if err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+
+	lines := map[string]int{}
+	var walk func(n *yaml.Node, prefix string)
+	walk = func(n *yaml.Node, prefix string) {
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			key := joinField(prefix, strings.ToLower(k.Value), ".")
+			lines[key] = k.Line
+			walk(v, key)
+		}
+	}
+	walk(doc.Content[0], "")
+
+	return lines
+}
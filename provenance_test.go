@@ -0,0 +1,118 @@
+package asp
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type provenanceTestConfig struct {
+	String   string
+	Int      int
+	Bool     bool
+	Secret   string `asp.sensitive:"true"`
+	Optional *int
+	Nested   struct {
+		Backends []Nested
+	}
+}
+
+func TestSourceString(t *testing.T) {
+	assert.Equal(t, "default", SourceDefault.String())
+	assert.Equal(t, "config", SourceConfig.String())
+	assert.Equal(t, "env", SourceEnv.String())
+	assert.Equal(t, "flag", SourceFlag.String())
+	assert.Equal(t, "Source(9)", Source(9).String())
+}
+
+func TestCandidateString(t *testing.T) {
+	assert.Equal(t, `default="5"`, Candidate{Source: SourceDefault, Value: "5"}.String())
+	assert.Equal(t, `env APP_INT="5"`, Candidate{Source: SourceEnv, Name: "APP_INT", Value: "5"}.String())
+	assert.Equal(t, `config app.yaml:3="5"`, Candidate{Source: SourceConfig, Name: "app.yaml", Value: "5", Line: 3}.String())
+}
+
+func TestProvenance(t *testing.T) {
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, provenanceTestConfig{Int: 1, Secret: "shh"})
+	assert.NoError(t, err)
+
+	t.Setenv("APP_INT", "7")
+	t.Setenv("APP_STRING", "env")
+	t.Setenv("APP_NESTED_BACKENDS_0_DUMMY", "3")
+	err = cmd.ParseFlags([]string{"--config", "asp_test_config.yaml", "--int", "9"})
+	assert.NoError(t, err)
+
+	provenance, err := a.Provenance()
+	assert.NoError(t, err)
+
+	byField := map[string]Provenance{}
+	for _, p := range provenance {
+		byField[p.Field] = p
+	}
+	assert.Len(t, byField, 6)
+
+	p := byField["Int"]
+	assert.Equal(t, Setting{Field: "Int", Key: "int", Flag: "int", Env: "APP_INT"}, p.Setting)
+	if assert.NotNil(t, p.Winner) {
+		assert.Equal(t, Candidate{Source: SourceFlag, Name: "--int", Value: "9"}, *p.Winner)
+	}
+	assert.Equal(t, []Candidate{
+		{Source: SourceEnv, Name: "APP_INT", Value: "7"},
+		{Source: SourceConfig, Name: "asp_test_config.yaml", Value: "5", Line: 6},
+		{Source: SourceDefault, Value: "1"},
+	}, p.Overridden)
+
+	p = byField["String"]
+	if assert.NotNil(t, p.Winner) {
+		assert.Equal(t, SourceEnv, p.Winner.Source)
+	}
+	assert.Equal(t, []Candidate{
+		{Source: SourceConfig, Name: "asp_test_config.yaml", Value: "hello", Line: 2},
+		{Source: SourceDefault, Value: ""},
+	}, p.Overridden)
+
+	p = byField["Bool"]
+	if assert.NotNil(t, p.Winner) {
+		assert.Equal(t, Candidate{Source: SourceConfig, Name: "asp_test_config.yaml", Value: "true", Line: 5}, *p.Winner)
+	}
+
+	p = byField["Secret"]
+	if assert.NotNil(t, p.Winner) {
+		assert.Equal(t, Candidate{Source: SourceDefault, Value: "[REDACTED]"}, *p.Winner)
+	}
+
+	assert.Nil(t, byField["Optional"].Winner)
+
+	p = byField["Nested.Backends"]
+	if assert.NotNil(t, p.Winner) {
+		assert.Equal(t, Candidate{Source: SourceEnv, Name: "APP_NESTED_BACKENDS_*", Value: "[map[dummy:3]]"}, *p.Winner)
+	}
+}
+
+func TestProvenanceMissingConfig(t *testing.T) {
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, provenanceTestConfig{})
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", "does-not-exist.yaml"})
+	assert.NoError(t, err)
+
+	_, err = a.Provenance()
+	assert.Error(t, err)
+}
+
+func TestYAMLLines(t *testing.T) {
+	lines := yamlLines("asp_test_config_keyed.yaml")
+	assert.Equal(t, map[string]int{
+		"databases":              1,
+		"databases.primary":      2,
+		"databases.primary.host": 3,
+		"databases.primary.port": 4,
+		"databases.replica":      5,
+		"databases.replica.host": 6,
+	}, lines)
+
+	assert.Nil(t, yamlLines("does-not-exist.yaml"))
+	assert.Nil(t, yamlLines("go.mod"))
+}
@@ -0,0 +1,253 @@
+package asp
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// ReferenceSetting describes a single setting for reference documentation.
+type ReferenceSetting struct {
+	Setting
+
+	Short       string // the short flag name (without the leading "-"), if any
+	Type        string // the flag type, as shown in the command's help
+	Default     string // the default value (redacted for sensitive settings)
+	Description string // the rendered description
+	Required    bool
+	Sensitive   bool
+}
+
+// ReferenceCommand describes the settings for an [Attach]ed command.
+type ReferenceCommand struct {
+	Path     string // the full command path, like "app serve"
+	Short    string // the command's short description
+	Settings []ReferenceSetting
+}
+
+// Reference walks the command tree from root and returns the settings for
+// every command with an [Attach]ed config, in the order that cobra lists the
+// commands.  A collection (slice or map of structs) has a setting for each
+// field of its items, just like the environment variables, and those don't
+// include a default.
+func Reference(root *cobra.Command) []ReferenceCommand {
+	commands := []ReferenceCommand{}
+
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if inst, ok := attachedInstance(cmd); ok {
+			commands = append(commands, ReferenceCommand{
+				Path:     cmd.CommandPath(),
+				Short:    cmd.Short,
+				Settings: inst.base().reference(),
+			})
+		}
+
+		for _, child := range cmd.Commands() {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	return commands
+}
+
+// reference returns the reference settings for every field.
+func (a *aspBase) reference() []ReferenceSetting {
+	settings := []ReferenceSetting{}
+
+	for _, f := range a.fields {
+		flag := a.cmd.PersistentFlags().Lookup(f.attrs.long)
+
+		def := ""
+		if f.hasDef && f.collection == nil {
+			def = f.def
+			if f.attrs.sensitive && def != "" {
+				def = "[REDACTED]"
+			}
+		}
+
+		for _, s := range f.envSettings() {
+			settings = append(settings, ReferenceSetting{
+				Setting:     s,
+				Short:       flag.Shorthand,
+				Type:        flag.Value.Type(),
+				Default:     def,
+				Description: flag.Usage,
+				Required:    f.attrs.required,
+				Sensitive:   f.attrs.sensitive,
+			})
+		}
+	}
+
+	return settings
+}
+
+// GenMarkdownReference writes a Markdown reference document, with a table of
+// settings for each [Attach]ed command in the tree.
+func GenMarkdownReference(root *cobra.Command, w io.Writer) error {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "# %s configuration reference\n", root.Name())
+
+	for _, c := range Reference(root) {
+		fmt.Fprintf(b, "\n## %s\n\n", c.Path)
+		if c.Short != "" {
+			fmt.Fprintf(b, "%s\n\n", markdownCell(c.Short))
+		}
+
+		b.WriteString("| Flag | Environment | Key | Type | Default | Required | Sensitive | Description |\n")
+		b.WriteString("| ---- | ----------- | --- | ---- | ------- | -------- | --------- | ----------- |\n")
+
+		for _, s := range c.Settings {
+			flag := "`--" + s.Flag + "`"
+			if s.Short != "" {
+				flag += ", `-" + s.Short + "`"
+			}
+
+			fmt.Fprintf(b, "| %s | `%s` | `%s` | %s | %s | %s | %s | %s |\n",
+				flag,
+				s.Env,
+				s.Key,
+				s.Type,
+				markdownCode(s.Default),
+				yesNo(s.Required),
+				yesNo(s.Sensitive),
+				markdownCell(s.Description))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ManOptions are the options for [GenManReference].
+type ManOptions struct {
+	// Title is the page title; by default, the upper-cased root command name.
+	Title string
+
+	// Section is the manual section; by default, "5" (file formats and
+	// conventions).
+	Section string
+
+	// Date, Source, and Manual fill in the rest of the page header, if given.
+	Date   string
+	Source string
+	Manual string
+}
+
+// GenManReference writes a reference man page (in roff), with the settings
+// for each [Attach]ed command in the tree.
+func GenManReference(root *cobra.Command, w io.Writer, options ManOptions) error {
+	if options.Title == "" {
+		options.Title = strings.ToUpper(root.Name())
+	}
+	if options.Section == "" {
+		options.Section = "5"
+	}
+
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, ".TH %s %s %s %s %s\n",
+		roffQuote(options.Title),
+		roffQuote(options.Section),
+		roffQuote(options.Date),
+		roffQuote(options.Source),
+		roffQuote(options.Manual))
+
+	b.WriteString(".SH NAME\n")
+	fmt.Fprintf(b, "%s \\- configuration reference\n", roffEscape(root.Name()))
+
+	b.WriteString(".SH DESCRIPTION\n")
+	b.WriteString("Each setting can be given by a command-line flag, an environment variable, or a config file key.\n")
+	b.WriteString("Flags take precedence over environment variables, which take precedence over the config file.\n")
+
+	for _, c := range Reference(root) {
+		fmt.Fprintf(b, ".SH %s\n", roffQuote(strings.ToUpper(c.Path)))
+		if c.Short != "" {
+			fmt.Fprintf(b, "%s\n", roffEscape(c.Short))
+		}
+
+		for _, s := range c.Settings {
+			b.WriteString(".TP\n")
+			fmt.Fprintf(b, "\\fB\\-\\-%s\\fP", roffEscape(s.Flag))
+			if s.Short != "" {
+				fmt.Fprintf(b, ", \\fB\\-%s\\fP", roffEscape(s.Short))
+			}
+			fmt.Fprintf(b, " \\fI%s\\fP\n", roffEscape(s.Type))
+
+			if s.Description != "" {
+				fmt.Fprintf(b, "%s\n", roffEscape(s.Description))
+			}
+
+			b.WriteString(".RS\n")
+			fmt.Fprintf(b, "Environment: \\fB%s\\fP\n.br\n", roffEscape(s.Env))
+			fmt.Fprintf(b, "Config key: \\fB%s\\fP\n", roffEscape(s.Key))
+			if s.Default != "" {
+				fmt.Fprintf(b, ".br\nDefault: %s\n", roffEscape(s.Default))
+			}
+			if s.Required {
+				b.WriteString(".br\nRequired.\n")
+			}
+			if s.Sensitive {
+				b.WriteString(".br\nSensitive; the value is redacted in output.\n")
+			}
+			b.WriteString(".RE\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// yesNo renders a boolean table cell.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return ""
+}
+
+var markdownCellEscaper = strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ")
+
+// markdownCell escapes a value for a Markdown table cell.
+func markdownCell(s string) string {
+	return markdownCellEscaper.Replace(s)
+}
+
+// markdownCode renders a (non-empty) value as inline code for a Markdown
+// table cell, using a longer backtick fence if the value contains backticks.
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+
+	pad := ""
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		pad = " "
+	}
+
+	return fence + pad + markdownCell(s) + pad + fence
+}
+
+var roffLineStart = regexp.MustCompile(`(?m)^([.'])`)
+
+// roffEscape escapes text for roff: backslashes and hyphens are escaped, and
+// lines that would otherwise start with a control character are protected.
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	return roffLineStart.ReplaceAllString(s, `\&$1`)
+}
+
+// roffQuote escapes and quotes a macro argument.
+func roffQuote(s string) string {
+	return `"` + strings.ReplaceAll(roffEscape(s), `"`, `""`) + `"`
+}
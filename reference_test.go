@@ -0,0 +1,158 @@
+package asp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReferenceTree(t *testing.T) *cobra.Command {
+	t.Helper()
+
+	root := &cobra.Command{Use: "app", Short: "runs the app"}
+	serve := &cobra.Command{Use: "serve", Short: "serves | things", Run: func(*cobra.Command, []string) {}}
+	other := &cobra.Command{Use: "other", Run: func(*cobra.Command, []string) {}}
+	root.AddCommand(serve, other)
+
+	err := Attach(root, commandTestConfig{Port: 80, Password: "secret"})
+	assert.NoError(t, err)
+
+	err = Attach(serve, struct {
+		Listen string `asp.short:"l" asp.desc:"the address to listen on"`
+	}{Listen: ":80"}, WithEnvPrefix("SRV_"))
+	assert.NoError(t, err)
+
+	return root
+}
+
+func TestReference(t *testing.T) {
+	root := newReferenceTree(t)
+
+	commands := Reference(root)
+	assert.Len(t, commands, 2)
+
+	assert.Equal(t, "app", commands[0].Path)
+	assert.Equal(t, "runs the app", commands[0].Short)
+	assert.Equal(t, []ReferenceSetting{
+		{
+			Setting:     Setting{Field: "Host", Key: "host", Flag: "host", Env: "APP_HOST"},
+			Type:        "string",
+			Description: "sets the host value (env: APP_HOST)",
+			Required:    true,
+		},
+		{
+			Setting:     Setting{Field: "Port", Key: "port", Flag: "port", Env: "APP_PORT"},
+			Type:        "int",
+			Default:     "80",
+			Description: "sets the port value (env: APP_PORT)",
+		},
+		{
+			Setting:     Setting{Field: "Password", Key: "password", Flag: "password", Env: "APP_PASSWORD"},
+			Type:        "string",
+			Default:     "[REDACTED]",
+			Description: "sets the password value (env: APP_PASSWORD)",
+			Sensitive:   true,
+		},
+		{
+			Setting:     Setting{Field: "Timeout", Key: "timeout", Flag: "timeout", Env: "APP_TIMEOUT"},
+			Type:        "duration",
+			Default:     "0s",
+			Description: "sets the timeout value (env: APP_TIMEOUT)",
+		},
+		{
+			Setting:     Setting{Field: "Tags", Key: "tags", Flag: "tags", Env: "APP_TAGS"},
+			Type:        "stringSlice",
+			Default:     "[]",
+			Description: "sets the tags value (env: APP_TAGS)",
+		},
+		{
+			Setting:     Setting{Field: "Optional", Key: "optional", Flag: "optional", Env: "APP_OPTIONAL"},
+			Type:        "int",
+			Description: "sets the optional value (env: APP_OPTIONAL)",
+		},
+		{
+			Setting:     Setting{Field: "Backends[<N>].Dummy", Key: "backends.<n>.dummy", Flag: "backends", Env: "APP_BACKENDS_<N>_DUMMY"},
+			Type:        "fields",
+			Description: "sets the backends value (env: APP_BACKENDS)",
+		},
+	}, commands[0].Settings)
+
+	assert.Equal(t, "app serve", commands[1].Path)
+	assert.Equal(t, []ReferenceSetting{
+		{
+			Setting:     Setting{Field: "Listen", Key: "listen", Flag: "listen", Env: "SRV_LISTEN"},
+			Short:       "l",
+			Type:        "string",
+			Default:     ":80",
+			Description: "the address to listen on (env: SRV_LISTEN)",
+		},
+	}, commands[1].Settings)
+
+	// running a command that isn't attached doesn't change anything, even
+	// though it inherits the root's context
+	root.SetArgs([]string{"other"})
+	err := root.Execute()
+	assert.NoError(t, err)
+	assert.Len(t, Reference(root), 2)
+
+	// nothing attached
+	assert.Empty(t, Reference(&cobra.Command{Use: "bare"}))
+}
+
+func TestGenMarkdownReference(t *testing.T) {
+	root := newReferenceTree(t)
+
+	out := &bytes.Buffer{}
+	err := GenMarkdownReference(root, out)
+	assert.NoError(t, err)
+
+	md := out.String()
+	assert.Contains(t, md, "# app configuration reference\n\n## app\n\nruns the app\n\n| Flag |")
+	assert.Contains(t, md, "| `--host` | `APP_HOST` | `host` | string |  | yes |  | sets the host value (env: APP_HOST) |\n")
+	assert.Contains(t, md, "| `--password` | `APP_PASSWORD` | `password` | string | `[REDACTED]` |  | yes | sets the password value (env: APP_PASSWORD) |\n")
+	assert.Contains(t, md, "\n## app serve\n\nserves \\| things\n\n")
+	assert.Contains(t, md, "| `--listen`, `-l` | `SRV_LISTEN` | `listen` | string | `:80` |  |  | the address to listen on (env: SRV_LISTEN) |\n")
+	assert.NotContains(t, md, "## app other")
+	assert.NotContains(t, md, "secret")
+}
+
+func TestGenManReference(t *testing.T) {
+	root := newReferenceTree(t)
+
+	out := &bytes.Buffer{}
+	err := GenManReference(root, out, ManOptions{Date: "Jan 2000"})
+	assert.NoError(t, err)
+
+	man := out.String()
+	assert.Contains(t, man, ".TH \"APP\" \"5\" \"Jan 2000\" \"\" \"\"\n.SH NAME\napp \\- configuration reference\n")
+	assert.Contains(t, man, `.SH "APP SERVE"
+serves | things
+.TP
+\fB\-\-listen\fP, \fB\-l\fP \fIstring\fP
+the address to listen on (env: SRV_LISTEN)
+.RS
+Environment: \fBSRV_LISTEN\fP
+.br
+Config key: \fBlisten\fP
+.br
+Default: :80
+.RE
+`)
+	assert.Contains(t, man, "Default: [REDACTED]\n.br\nSensitive; the value is redacted in output.\n")
+	assert.NotContains(t, man, "secret")
+}
+
+func TestMarkdownCode(t *testing.T) {
+	assert.Equal(t, "", markdownCode(""))
+	assert.Equal(t, "`a\\|b`", markdownCode("a|b"))
+	assert.Equal(t, "``a`b``", markdownCode("a`b"))
+	assert.Equal(t, "`` `a ``", markdownCode("`a"))
+}
+
+func TestRoffEscape(t *testing.T) {
+	assert.Equal(t, `a\-b\ec`, roffEscape(`a-b\c`))
+	assert.Equal(t, "\\&.start\n\\&'quote", roffEscape(".start\n'quote"))
+	assert.Equal(t, `"say ""hi"""`, roffQuote(`say "hi"`))
+}
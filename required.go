@@ -0,0 +1,46 @@
+package asp
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrRequiredMissing is the sentinel (wrapped by [RequiredError]) that
+// indicates one or more `asp.required` settings were not provided.
+var ErrRequiredMissing = errors.New("required configuration values are missing")
+
+// RequiredError is returned by [Asp.Config] when one or more required settings
+// were not provided by a flag, environment variable, or config file.  It lists
+// *all* of the missing settings, not just the first.
+type RequiredError struct {
+	Missing []Setting
+}
+
+// Error lists each of the missing settings.
+func (e *RequiredError) Error() string {
+	missing := mapSlice(e.Missing, Setting.String)
+	return ErrRequiredMissing.Error() + ": " + strings.Join(missing, "; ")
+}
+
+// Unwrap allows [errors.Is] to match [ErrRequiredMissing].
+func (e *RequiredError) Unwrap() error {
+	return ErrRequiredMissing
+}
+
+// checkRequired returns a [RequiredError] if any required settings have not
+// been supplied.
+func (a *aspBase) checkRequired() error {
+	missing := []Setting{}
+
+	for _, f := range a.fields {
+		if f.attrs.required && !a.isSupplied(f) {
+			missing = append(missing, f.setting())
+		}
+	}
+
+	if len(missing) > 0 {
+		return &RequiredError{Missing: missing}
+	}
+
+	return nil
+}
@@ -0,0 +1,325 @@
+package asp
+
+import (
+	"encoding"
+	"encoding/json"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// SchemaOptions are the options for [JSONSchema].
+type SchemaOptions struct {
+	// ID is the schema's "$id", if any.
+	ID string
+
+	// Title is the schema's title, if any.
+	Title string
+
+	// EnvPrefix is the environment variable prefix used in the descriptions;
+	// it should be the same one passed to [WithEnvPrefix], or "APP" if none
+	// was.
+	EnvPrefix string
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) for the config file that
+// [Asp.Config] reads, with the descriptions, defaults (when not the zero
+// value), required values, and constraints from the given config.  Editors
+// can use the schema to autocomplete and check config files, and it can also
+// be used to validate config files before deploying them.
+//
+// Note that a required setting may also be supplied by a flag or environment
+// variable, in which case the config file doesn't need it; the schema can't
+// know that, so it always lists required settings as required.
+func JSONSchema[T Config](defaults T, options SchemaOptions) ([]byte, error) {
+	a := &aspBase{decodeHook: DefaultDecodeHook}
+	return a.jsonSchema(defaults, options)
+}
+
+// durationPattern matches the strings that [time.ParseDuration] accepts.
+const durationPattern = `^[-+]?(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|μs|ms|s|m|h))+)$`
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	bytesType    = reflect.TypeOf([]byte{})
+	ipType       = reflect.TypeOf(net.IP{})
+	ipNetType    = reflect.TypeOf(net.IPNet{})
+	urlType      = reflect.TypeOf(url.URL{})
+
+	flagValueType       = reflect.TypeOf((*pflag.Value)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// jsonSchema creates the JSON Schema for the config.
+func (a *aspBase) jsonSchema(cfg any, options SchemaOptions) ([]byte, error) {
+	v := reflect.ValueOf(cfg)
+	if reflect.Indirect(v).Kind() != reflect.Struct {
+		return nil, ErrConfigMustBeStruct
+	}
+
+	schema, err := a.structSchema(reflect.Indirect(v), attrs{env: strings.TrimRight(options.EnvPrefix, "_")})
+	if err != nil {
+		return nil, err
+	}
+
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	if options.ID != "" {
+		schema["$id"] = options.ID
+	}
+	if options.Title != "" {
+		schema["title"] = options.Title
+	}
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// structSchema creates the schema for a (nested) struct; the logic is very
+// similar to [processStructInner].
+func (a *aspBase) structSchema(structVal reflect.Value, parentAttrs attrs) (map[string]any, error) {
+	properties := map[string]any{}
+	required := []string{}
+
+	for _, f := range reflect.VisibleFields(structVal.Type()) {
+		if !f.IsExported() || len(f.Index) > 1 {
+			continue
+		}
+
+		childAttrs := getAttributes(f)
+		if childAttrs.ignored {
+			continue
+		}
+
+		joinedAttrs := parentAttrs.join(childAttrs)
+
+		fieldVal := structVal.FieldByIndex(f.Index)
+		fieldType := f.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+			if fieldVal.IsNil() {
+				fieldVal = reflect.Zero(fieldType)
+			} else {
+				fieldVal = fieldVal.Elem()
+			}
+		}
+
+		key := strings.ToLower(childAttrs.name)
+
+		if isNestedStruct(fieldType) {
+			recursiveAttrs := joinedAttrs
+			if f.Anonymous {
+				recursiveAttrs = parentAttrs
+			}
+
+			schema, err := a.structSchema(fieldVal, recursiveAttrs)
+			if err != nil {
+				return nil, err
+			}
+
+			if !f.Anonymous {
+				properties[key] = schema
+				continue
+			}
+
+			// embedded structs are "inlined", just like their settings keys
+			for k, v := range schema["properties"].(map[string]any) {
+				properties[k] = v
+			}
+			if r, ok := schema["required"].([]string); ok {
+				required = append(required, r...)
+			}
+			continue
+		}
+
+		var schema map[string]any
+		var err error
+		if isCollection(fieldType) {
+			schema, err = a.collectionSchema(fieldType, joinedAttrs)
+		} else {
+			schema = leafSchema(fieldType)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		desc, err := describe(f.Name, joinedAttrs, parentAttrs)
+		if err != nil {
+			return nil, err
+		}
+		if desc != "" {
+			schema["description"] = desc
+		}
+
+		if joinedAttrs.def != "" && fieldVal.IsZero() {
+			fieldVal, err = a.decodeDefault(joinedAttrs.def, fieldType)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "default for %s (%q)", f.Name, joinedAttrs.def)
+			}
+		}
+
+		if joinedAttrs.sensitive {
+			schema["writeOnly"] = true
+		} else if !fieldVal.IsZero() {
+			schema["default"] = plainValue(fieldVal, false, joinedAttrs)
+		}
+
+		if joinedAttrs.required {
+			required = append(required, key)
+		}
+
+		c, err := a.parseConstraints(joinedAttrs, fieldType)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "on %s", f.Name)
+		}
+		addConstraints(schema, c)
+
+		properties[key] = schema
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema, nil
+}
+
+// collectionSchema creates the schema for a slice (or map) of structs.  The
+// item field descriptions use placeholders for the index (or name) in the
+// environment variables, like `APP_UPSTREAMS_<N>_HOST`.
+func (a *aspBase) collectionSchema(t reflect.Type, collectionAttrs attrs) (map[string]any, error) {
+	placeholder := "<N>"
+	if t.Kind() == reflect.Map {
+		placeholder = "<NAME>"
+	}
+
+	itemSchema, err := a.structSchema(reflect.Zero(t.Elem()), attrs{
+		env:       collectionAttrs.env + "_" + placeholder,
+		sensitive: collectionAttrs.sensitive,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Kind() == reflect.Map {
+		return map[string]any{"type": "object", "additionalProperties": itemSchema}, nil
+	}
+	return map[string]any{"type": "array", "items": itemSchema}, nil
+}
+
+// leafSchema creates the schema for a plain (non-struct) value, in the form
+// that config files use.
+func leafSchema(t reflect.Type) map[string]any {
+	// special cases first...
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	case bytesType:
+		return map[string]any{"type": "string", "contentEncoding": "base16", "pattern": "^([0-9a-fA-F]{2})*$"}
+	case ipType, ipNetType:
+		return map[string]any{"type": "string"}
+	case urlType:
+		return map[string]any{"type": "string", "format": "uri-reference"}
+	}
+
+	// Just like processing, types that know how to parse themselves are
+	// given as strings.
+	ptr := reflect.PointerTo(t)
+	if ptr.Implements(flagValueType) || ptr.Implements(textUnmarshalerType) {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		bits := t.Bits()
+		return map[string]any{
+			"type":    "integer",
+			"minimum": -(int64(1) << (bits - 1)),
+			"maximum": int64(1)<<(bits-1) - 1,
+		}
+
+	case reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": uint64(math.MaxUint64) >> (64 - t.Bits())}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": leafSchema(t.Elem())}
+
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": leafSchema(t.Elem())}
+	}
+
+	return map[string]any{}
+}
+
+// addConstraints adds the constraints to the schema, where the schema can
+// express them.
+func addConstraints(schema map[string]any, c *constraints) {
+	bounds := func(minKey string, maxKey string) {
+		if c.min != nil {
+			schema[minKey] = c.min.Interface()
+		}
+		if c.max != nil {
+			schema[maxKey] = c.max.Interface()
+		}
+	}
+
+	switch schema["type"] {
+	case "integer", "number":
+		if !c.length {
+			bounds("minimum", "maximum")
+		}
+	case "string":
+		if c.length {
+			bounds("minLength", "maxLength")
+		}
+	case "array":
+		bounds("minItems", "maxItems")
+	case "object":
+		bounds("minProperties", "maxProperties")
+	}
+
+	// oneof and pattern apply to each element of a list
+	elemSchema := schema
+	if items, ok := schema["items"].(map[string]any); ok {
+		elemSchema = items
+	}
+
+	if len(c.oneOf) > 0 {
+		elemSchema["enum"] = mapSlice(c.oneOf, func(v reflect.Value) any { return plainValue(v, false, attrs{}) })
+	}
+
+	if c.pattern != nil {
+		elemSchema["pattern"] = c.pattern.String()
+	}
+}
@@ -0,0 +1,321 @@
+package asp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+type schemaTestConfig struct {
+	Host    string        `asp.desc:"the host" asp.required:"true" asp.pattern:"^[a-z.]+$"`
+	Port    int           `asp.min:"1" asp.max:"65535"`
+	Level   string        `asp.default:"info" asp.oneof:"debug,info,warn"`
+	Name    string        `asp.min:"2" asp.max:"10"`
+	Timeout time.Duration `asp.default:"30s"`
+	Started time.Time
+	Key     []byte
+	Small   int8
+	Tiny    uint8
+	Ratio   float64
+	Secret  string   `asp.sensitive:"true"`
+	Tags    []string `asp.max:"3" asp.oneof:"a,b,c"`
+	Labels  map[string]int
+	Link    *url.URL
+	Region  regionCode
+	Nested  struct {
+		Enabled bool `asp.required:"true"`
+	}
+	AnonymousEmbedded
+	Upstreams []upstream
+	Databases map[string]database `asp.min:"1"`
+}
+
+func schemaProperties(t *testing.T, b []byte) (map[string]any, map[string]any) {
+	t.Helper()
+
+	schema := map[string]any{}
+	err := json.Unmarshal(b, &schema)
+	assert.NoError(t, err)
+
+	properties, _ := schema["properties"].(map[string]any)
+	return schema, properties
+}
+
+func TestJSONSchema(t *testing.T) {
+	b, err := JSONSchema(schemaTestConfig{Port: 80}, SchemaOptions{
+		ID:        "https://example.com/app.schema.json",
+		Title:     "app",
+		EnvPrefix: "MY_",
+	})
+	assert.NoError(t, err)
+
+	schema, properties := schemaProperties(t, b)
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+	assert.Equal(t, "https://example.com/app.schema.json", schema["$id"])
+	assert.Equal(t, "app", schema["title"])
+	assert.Equal(t, "object", schema["type"])
+	assert.Equal(t, false, schema["additionalProperties"])
+	assert.Equal(t, []any{"host"}, schema["required"])
+
+	cases := map[string]map[string]any{
+		"host": {
+			"type":        "string",
+			"description": "the host (env: MY_HOST)",
+			"pattern":     "^[a-z.]+$",
+		},
+		"port": {
+			"type":        "integer",
+			"description": "sets the port value (env: MY_PORT)",
+			"default":     80.0,
+			"minimum":     1.0,
+			"maximum":     65535.0,
+		},
+		"level": {
+			"type":        "string",
+			"description": "sets the level value (env: MY_LEVEL)",
+			"default":     "info",
+			"enum":        []any{"debug", "info", "warn"},
+		},
+		"name": {
+			"type":        "string",
+			"description": "sets the name value (env: MY_NAME)",
+			"minLength":   2.0,
+			"maxLength":   10.0,
+		},
+		"timeout": {
+			"type":        "string",
+			"description": "sets the timeout value (env: MY_TIMEOUT)",
+			"default":     "30s",
+			"pattern":     durationPattern,
+		},
+		"started": {
+			"type":        "string",
+			"description": "sets the started value (env: MY_STARTED)",
+			"format":      "date-time",
+		},
+		"key": {
+			"type":            "string",
+			"description":     "sets the key value (env: MY_KEY)",
+			"contentEncoding": "base16",
+			"pattern":         "^([0-9a-fA-F]{2})*$",
+		},
+		"small": {
+			"type":        "integer",
+			"description": "sets the small value (env: MY_SMALL)",
+			"minimum":     -128.0,
+			"maximum":     127.0,
+		},
+		"tiny": {
+			"type":        "integer",
+			"description": "sets the tiny value (env: MY_TINY)",
+			"minimum":     0.0,
+			"maximum":     255.0,
+		},
+		"ratio": {
+			"type":        "number",
+			"description": "sets the ratio value (env: MY_RATIO)",
+		},
+		"secret": {
+			"type":        "string",
+			"description": "sets the secret value (env: MY_SECRET)",
+			"writeOnly":   true,
+		},
+		"tags": {
+			"type":        "array",
+			"description": "sets the tags value (env: MY_TAGS)",
+			"items":       map[string]any{"type": "string", "enum": []any{"a", "b", "c"}},
+			"maxItems":    3.0,
+		},
+		"labels": {
+			"type":                 "object",
+			"description":          "sets the labels value (env: MY_LABELS)",
+			"additionalProperties": map[string]any{"type": "integer"},
+		},
+		"link": {
+			"type":        "string",
+			"description": "sets the link value (env: MY_LINK)",
+			"format":      "uri-reference",
+		},
+		"region": {
+			"type":        "string",
+			"description": "sets the region value (env: MY_REGION)",
+		},
+		"nested": {
+			"type":                 "object",
+			"additionalProperties": false,
+			"required":             []any{"enabled"},
+			"properties": map[string]any{
+				"enabled": map[string]any{
+					"type":        "boolean",
+					"description": "sets the nested enabled value (env: MY_NESTED_ENABLED)",
+				},
+			},
+		},
+		// embedded fields are inlined
+		"embeddedint": {
+			"type":        "integer",
+			"description": "sets the embedded int value (env: MY_EMBEDDEDINT)",
+		},
+	}
+
+	for key, expected := range cases {
+		assert.Equal(t, expected, properties[key], key)
+	}
+}
+
+func TestJSONSchemaCollections(t *testing.T) {
+	b, err := JSONSchema(schemaTestConfig{}, SchemaOptions{})
+	assert.NoError(t, err)
+
+	_, properties := schemaProperties(t, b)
+
+	upstreams := properties["upstreams"].(map[string]any)
+	assert.Equal(t, "array", upstreams["type"])
+	items := upstreams["items"].(map[string]any)
+	assert.Equal(t, "object", items["type"])
+	assert.Equal(t, map[string]any{
+		"type":        "string",
+		"description": "sets the host value (env: UPSTREAMS_<N>_HOST)",
+	}, items["properties"].(map[string]any)["host"])
+
+	databases := properties["databases"].(map[string]any)
+	assert.Equal(t, "object", databases["type"])
+	assert.Equal(t, 1.0, databases["minProperties"])
+	items = databases["additionalProperties"].(map[string]any)
+	assert.Equal(t, map[string]any{
+		"type":        "string",
+		"description": "sets the password value (env: DATABASES_<NAME>_PASSWORD)",
+		"writeOnly":   true,
+	}, items["properties"].(map[string]any)["password"])
+}
+
+// starterConfig is like a typical config, with multi-word fields at each
+// level.
+type starterConfig struct {
+	SomeValue   string `asp.required:"true"`
+	ManyNumbers []int
+	SubSection  struct {
+		NamesLikeThis string `asp.required:"true"`
+	}
+	MapStringInt map[string]int
+	Upstreams    []upstream
+}
+
+// schemaErrors is a (very) minimal JSON Schema validator, covering just the
+// keywords that affect the shape of a config file.
+func schemaErrors(schema map[string]any, value any, path string) []string {
+	errs := []string{}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected an object", path))
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, r := range required {
+			if _, ok := obj[r.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing %s", path, r))
+			}
+		}
+		for k, v := range obj {
+			if prop, ok := properties[k].(map[string]any); ok {
+				errs = append(errs, schemaErrors(prop, v, path+"."+k)...)
+			} else if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+				errs = append(errs, schemaErrors(additional, v, path+"."+k)...)
+			} else if schema["additionalProperties"] == false {
+				errs = append(errs, fmt.Sprintf("%s: unexpected %s", path, k))
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected an array", path))
+		}
+		for i, v := range arr {
+			errs = append(errs, schemaErrors(schema["items"].(map[string]any), v, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			errs = append(errs, fmt.Sprintf("%s: expected a string", path))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, fmt.Sprintf("%s: expected a boolean", path))
+		}
+	case "integer":
+		if _, ok := value.(int); !ok {
+			errs = append(errs, fmt.Sprintf("%s: expected an integer", path))
+		}
+	}
+
+	return errs
+}
+
+// A starter config file (as from `config init`) should be valid against the
+// schema for the same config.
+func TestJSONSchemaStarterConfig(t *testing.T) {
+	cfg := starterConfig{SomeValue: "x", ManyNumbers: []int{1, 2}, MapStringInt: map[string]int{"a": 1}}
+	cfg.SubSection.NamesLikeThis = "y"
+	cfg.Upstreams = []upstream{{Host: "example.com"}}
+
+	b, err := JSONSchema(cfg, SchemaOptions{})
+	assert.NoError(t, err)
+	schema, _ := schemaProperties(t, b)
+
+	data, err := SerializeConfigFile(cfg, "yaml", ConfigFileOptions{})
+	assert.NoError(t, err)
+
+	file := map[string]any{}
+	err = yaml.Unmarshal(data, &file)
+	assert.NoError(t, err)
+	assert.Empty(t, schemaErrors(schema, file, ""))
+
+	// ... and a misspelled key isn't
+	file["somevalu"] = "x"
+	assert.Equal(t, []string{": unexpected somevalu"}, schemaErrors(schema, file, ""))
+}
+
+// The schema's own patterns should agree with what the config accepts.
+func TestJSONSchemaDurationPattern(t *testing.T) {
+	re := regexp.MustCompile(durationPattern)
+
+	for _, s := range []string{"0", "30s", "1h30m", "-1.5h", "+.5s", "300ms", "2us", "2µs"} {
+		_, err := time.ParseDuration(s)
+		assert.NoError(t, err, s)
+		assert.True(t, re.MatchString(s), s)
+	}
+
+	for _, s := range []string{"", "1", "1x", "h", "1h 30m", "--1s"} {
+		_, err := time.ParseDuration(s)
+		assert.Error(t, err, s)
+		assert.False(t, re.MatchString(s), s)
+	}
+}
+
+func TestJSONSchemaErrors(t *testing.T) {
+	_, err := JSONSchema(1, SchemaOptions{})
+	assert.ErrorIs(t, err, ErrConfigMustBeStruct)
+
+	_, err = JSONSchema(struct {
+		Bad string `asp.desc:"{{ .Bogus"`
+	}{}, SchemaOptions{})
+	assert.Error(t, err)
+
+	_, err = JSONSchema(struct {
+		Bad int `asp.min:"nope"`
+	}{}, SchemaOptions{})
+	assert.Error(t, err)
+
+	_, err = JSONSchema(struct {
+		Bad int `asp.default:"nope"`
+	}{}, SchemaOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package asp
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// defaultConfigPaths are the directories searched for the default config
+// file, in order of precedence.
+var defaultConfigPaths = []string{".", "$HOME/.config", "$HOME", "/etc"}
+
+// configSearch returns the default config files to look for (as paths without
+// an extension), in order of precedence: the default config name in each of
+// the config paths, and then, with [WithXDGConfig], `<name>/config` in each of
+// the XDG config directories.
+func (a *aspBase) configSearch() []string {
+	if a.defaultCfgName == "" {
+		return nil
+	}
+
+	search := []string{}
+
+	for _, dir := range a.configPaths {
+		search = append(search, filepath.Join(dir, a.defaultCfgName))
+	}
+
+	if a.xdgConfig {
+		for _, dir := range xdgConfigDirs() {
+			search = append(search, filepath.Join(dir, a.defaultCfgName, "config"))
+		}
+	}
+
+	return search
+}
+
+// findDefaultConfigFile returns the highest-precedence default config file
+// that exists, or "" if there isn't one.
+func (a *aspBase) findDefaultConfigFile() string {
+	for _, path := range a.configSearch() {
+		if file := findConfigFile(path); file != "" {
+			return file
+		}
+	}
+
+	return ""
+}
+
+// findDefaultConfigFiles returns *every* default config file that exists,
+// lowest precedence first (which is merge order).
+func (a *aspBase) findDefaultConfigFiles() []string {
+	search := a.configSearch()
+	files := []string{}
+
+	for i := len(search) - 1; i >= 0; i-- {
+		if file := findConfigFile(search[i]); file != "" {
+			files = append(files, file)
+		}
+	}
+
+	return files
+}
+
+// findConfigFile looks for a config file at the path (after expanding any
+// environment variables) with any of the extensions viper supports, returning
+// "" if there isn't one.
+func findConfigFile(path string) string {
+	path, err := filepath.Abs(os.ExpandEnv(path))
+	if err != nil {
+		return ""
+	}
+
+	for _, ext := range viper.SupportedExts {
+		file := path + "." + ext
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+			return file
+		}
+	}
+
+	return ""
+}
+
+// xdgConfigDirs returns the XDG base directories for config files, in order of
+// precedence: `$XDG_CONFIG_HOME` (or `$HOME/.config`), and then each of the
+// `$XDG_CONFIG_DIRS` (or `/etc/xdg`).  As the spec requires, relative paths
+// are ignored.
+func xdgConfigDirs() []string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
+	}
+
+	dirs := []string{}
+	for _, dir := range append([]string{configHome}, filepath.SplitList(configDirs)...) {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
@@ -0,0 +1,131 @@
+package asp
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigSearch(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/home")
+	t.Setenv("XDG_CONFIG_DIRS", "/xdg/one:relative:/xdg/two")
+
+	tests := []struct {
+		name     string
+		options  []Option
+		expected []string
+	}{
+		{"no name", nil, nil},
+		{"default", []Option{WithDefaultConfigName("app")}, []string{
+			"app", "$HOME/.config/app", "$HOME/app", "/etc/app",
+		}},
+		{"config paths", []Option{WithDefaultConfigName("app"), WithConfigPaths("/opt/app", "$APP_DIR")}, []string{
+			"/opt/app/app", "$APP_DIR/app",
+		}},
+		{"xdg", []Option{WithDefaultConfigName("app"), WithXDGConfig}, []string{
+			"/xdg/home/app/config", "/xdg/one/app/config", "/xdg/two/app/config",
+		}},
+		{"xdg and config paths", []Option{WithDefaultConfigName("app"), WithXDGConfig, WithConfigPaths(".")}, []string{
+			"app", "/xdg/home/app/config", "/xdg/one/app/config", "/xdg/two/app/config",
+		}},
+		{"no paths", []Option{WithDefaultConfigName("app"), WithConfigPaths()}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := AttachInstance(&cobra.Command{}, layersTestConfig{}, tt.options...)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, a.(*asp[layersTestConfig]).configSearch())
+		})
+	}
+}
+
+func TestXDGConfigDirs(t *testing.T) {
+	t.Setenv("HOME", "/home/someone")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_CONFIG_DIRS", "")
+	assert.Equal(t, []string{"/home/someone/.config", "/etc/xdg"}, xdgConfigDirs())
+
+	t.Setenv("XDG_CONFIG_HOME", "relative")
+	t.Setenv("XDG_CONFIG_DIRS", "/one:/two")
+	assert.Equal(t, []string{"/one", "/two"}, xdgConfigDirs())
+}
+
+func TestXDGConfig(t *testing.T) {
+	configHome := t.TempDir()
+	configDir1 := t.TempDir()
+	configDir2 := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("XDG_CONFIG_DIRS", configDir1+string(filepath.ListSeparator)+configDir2)
+
+	name := "asp-xdg-test"
+
+	// a bare name.yaml isn't an XDG config file
+	writeLayer(t, filepath.Join(configHome, name+".yaml"), "host: bare\n")
+	dir1File := writeLayer(t, filepath.Join(configDir1, name, "config.toml"), "host = \"dir1\"\nport = 1\n")
+	dir2File := writeLayer(t, filepath.Join(configDir2, name, "config.yaml"), "host: dir2\ntags: [a]\n")
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, layersTestConfig{}, WithDefaultConfigName(name), WithXDGConfig)
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "dir1", cfg.Host)
+	assert.Equal(t, 1, cfg.Port)
+	assert.Empty(t, cfg.Tags)
+	assert.Equal(t, []string{dir1File}, a.ConfigFiles())
+
+	// the user's config wins, once there is one
+	homeFile := writeLayer(t, filepath.Join(configHome, name, "config.json"), `{"host": "home"}`)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "home", cfg.Host)
+	assert.Equal(t, []string{homeFile}, a.ConfigFiles())
+
+	// with layered config, they're all merged
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, layersTestConfig{}, WithDefaultConfigName(name), WithXDGConfig, WithLayeredConfig)
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "home", cfg.Host)
+	assert.Equal(t, 1, cfg.Port)
+	assert.Equal(t, []string{"a"}, cfg.Tags)
+	assert.Equal(t, []string{dir2File, dir1File, homeFile}, a.ConfigFiles())
+}
+
+func TestConfigPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := t.TempDir()
+	t.Setenv("ASP_TEST_DIR", dir)
+
+	name := "asp-paths-test"
+	file := writeLayer(t, filepath.Join(dir, name+".yaml"), "port: 1\n")
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, layersTestConfig{}, WithDefaultConfigName(name), WithConfigPaths("$ASP_TEST_DIR"))
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cfg.Port)
+	assert.Equal(t, []string{file}, a.ConfigFiles())
+
+	// the default paths aren't searched
+	writeLayer(t, filepath.Join(home, name+".yaml"), "port: 2\n")
+
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, layersTestConfig{}, WithDefaultConfigName(name), WithConfigPaths("/nonexistent"))
+	assert.NoError(t, err)
+
+	cfg, err = a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cfg.Port)
+	assert.Empty(t, a.ConfigFiles())
+}
@@ -2,16 +2,25 @@ package asp
 
 import (
 	"cmp"
+	"encoding"
+	"encoding/csv"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"maps"
+	"net"
+	"net/netip"
+	"net/url"
 	"reflect"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/spf13/pflag"
+
 	"github.com/jaredreisinger/asp/decoders"
 )
 
@@ -22,6 +31,152 @@ func SerializeFlags[T Config](cfg T, omitEmpty bool) (string, error) {
 	return serializeStruct(cfg, omitEmpty)
 }
 
+// ErrZeroValueNotSerializable indicates that [SerializeArgs] can't give a
+// value exactly as a flag, because it's the zero value of a custom type whose
+// own parsing rejects it.
+var ErrZeroValueNotSerializable = errors.New("zero value can't be given as a flag")
+
+// SerializeArgs returns the CLI flags that would re-create the given
+// configuration values exactly, as an argument list suitable for
+// [os/exec.Command].  Each flag is a single "--name=value" argument, and zero
+// values (including empty lists and maps, as "--name=") are given explicitly,
+// so that the command's own defaults don't matter.  Nil optional fields are
+// omitted.  If a value can't be given exactly (see
+// [ErrZeroValueNotSerializable]), an error is returned.  Unlike
+// [SerializeFlags], sensitive values are *not* redacted, so take care with the
+// result.
+func SerializeArgs[T Config](cfg T) ([]string, error) {
+	return serializeArgs(cfg)
+}
+
+// SerializeShell is like [SerializeArgs], but returns the flags as a single
+// string, quoted as needed for a POSIX shell.
+func SerializeShell[T Config](cfg T) (string, error) {
+	args, err := serializeArgs(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(mapSlice(args, quoteShell), " "), nil
+}
+
+// serializeArgs is the non-generic implementation of [SerializeArgs].
+func serializeArgs(s interface{}) ([]string, error) {
+	serialized, err := serializeStructInner(s, serializeOptions{exact: true}, attrs{})
+	if err != nil {
+		return nil, err
+	}
+
+	return mapSlice(serialized, func(flag serializedFlag) string {
+		return fmt.Sprintf("--%s=%s", flag.long, flag.value)
+	}), nil
+}
+
+// shellSafe matches strings that need no quoting in a POSIX shell.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// quoteShell single-quotes a string for a POSIX shell, if needed.  Inside
+// single quotes, everything is literal except the single quote itself.
+func quoteShell(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// SerializeEnv returns the environment variables (as `NAME=value` strings)
+// that would re-create the given configuration values, with the exception of
+// any redacted sensitive values (which are replaced with [REDACTED] in the
+// returned value).  The prefix should be the same one passed to
+// [WithEnvPrefix], or "APP" if none was.  The values are not quoted or escaped
+// in any way, which is what [os/exec.Cmd] and Docker's `--env-file` expect.
+func SerializeEnv[T Config](cfg T, prefix string, omitEmpty bool) ([]string, error) {
+	serialized, err := serializeEnv(cfg, prefix, omitEmpty)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make([]string, 0, len(serialized))
+	for _, v := range serialized {
+		value := v.value
+		if v.sensitive && value != "" {
+			value = "[REDACTED]"
+		}
+		env = append(env, v.env+"="+value)
+	}
+
+	return env, nil
+}
+
+// SerializeDotEnv is like [SerializeEnv], but returns the variables in
+// "dotenv" syntax, one per line, with each value double-quoted and escaped as
+// needed.  This is suitable for `.env` files and for systemd's
+// `EnvironmentFile=`.
+func SerializeDotEnv[T Config](cfg T, prefix string, omitEmpty bool) (string, error) {
+	serialized, err := serializeEnv(cfg, prefix, omitEmpty)
+	if err != nil {
+		return "", err
+	}
+
+	str := &strings.Builder{}
+	for _, v := range serialized {
+		formattedValue := quoteDotEnv(v.value)
+		if v.sensitive && v.value != "" {
+			formattedValue = "[REDACTED]"
+		}
+		fmt.Fprintf(str, "%s=%s\n", v.env, formattedValue)
+	}
+
+	return str.String(), nil
+}
+
+// serializeEnv serializes the config with environment variable names, and
+// flattens any collections into their individual (indexed or named) item
+// fields.  Since the environment can't distinguish an empty item field from a
+// missing one, those are always omitted.
+func serializeEnv(s interface{}, prefix string, omitEmpty bool) ([]serializedFlag, error) {
+	serialized, err := serializeStructInner(s, serializeOptions{omitEmpty: omitEmpty}, attrs{env: strings.TrimRight(prefix, "_")})
+	if err != nil {
+		return nil, err
+	}
+
+	env := []serializedFlag{}
+	for _, v := range serialized {
+		if v.env == "" {
+			// a collection item
+			for _, leaf := range v.leaves {
+				if leaf.value != "" {
+					env = append(env, leaf)
+				}
+			}
+			continue
+		}
+
+		if v.value == "" && omitEmpty {
+			continue
+		}
+
+		env = append(env, v)
+	}
+
+	return env, nil
+}
+
+// dotEnvEscaper escapes the characters that are special inside a
+// double-quoted dotenv value.
+var dotEnvEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	`$`, `\$`,
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// quoteDotEnv double-quotes a value for a dotenv file.
+func quoteDotEnv(s string) string {
+	return `"` + dotEnvEscaper.Replace(s) + `"`
+}
+
 // serializeStruct is the main entrypoint for serializing CLI flags for logging
 // purposes. The omitEmpty flag specifies whether empty/zero/default values
 // should be omitted from the serialization.
@@ -31,7 +186,7 @@ func serializeStruct(s interface{}, omitEmpty bool) (string, error) {
 	// type/defaults. (We could insist on a struct value, and not a
 	// point-to-struct.) But I don't think there's any *particular* reason to
 	// force this.
-	str, err := serializeStructInner(s, omitEmpty, attrs{sensitive: false})
+	serialized, err := serializeStructInner(s, serializeOptions{omitEmpty: omitEmpty}, attrs{sensitive: false})
 	if err != nil {
 		return "", err
 	}
@@ -39,22 +194,73 @@ func serializeStruct(s interface{}, omitEmpty bool) (string, error) {
 	// We don't have to check the kind of `s`... if it got through
 	// serializeStructInner, the kind is acceptable!
 	// a.baseType = reflect.Indirect(reflect.ValueOf(s)).Type()
-	return str, nil
+	str := &strings.Builder{}
+
+	for _, flag := range serialized {
+		if flag.value == "" && omitEmpty {
+			continue
+		}
+
+		if str.Len() > 0 {
+			str.WriteString(" ")
+		}
+		formattedValue := fmt.Sprintf("%q", flag.redactedValue())
+		if flag.sensitive && flag.value != "" {
+			formattedValue = "[REDACTED]"
+		}
+		fmt.Fprintf(str, "--%s %s", flag.long, formattedValue)
+	}
+
+	return str.String(), nil
+}
+
+// serializedFlag is a single (unformatted) flag and value; collections of
+// structs result in the same flag appearing more than once, with the item's
+// individual fields in leaves.
+type serializedFlag struct {
+	long      string
+	env       string
+	value     string
+	sensitive bool
+	leaves    []serializedFlag
+
+	// redacted is a collection item's value with its sensitive fields
+	// redacted.
+	redacted string
+}
+
+// redactedValue returns the value, with any sensitive collection item fields
+// redacted.  (Other sensitive values are redacted entirely by the caller.)
+func (f serializedFlag) redactedValue() string {
+	if f.leaves != nil {
+		return f.redacted
+	}
+	return f.value
+}
+
+// serializeOptions control how [serializeStructInner] formats values.
+type serializeOptions struct {
+	omitEmpty bool
+
+	// exact formats zero values explicitly (like "0" or "false") rather than
+	// as empty, so that the flags re-create them exactly.  Nil optional
+	// fields, and any empty values that the flag can't parse, are omitted.
+	exact bool
 }
 
 // serializeStructInner is the (recursive) workhorse that serializes a
 // (sub-)struct config; the logic is very similar to [processStructInner].
-func serializeStructInner(s interface{}, omitEmpty bool, parentAttrs attrs) (string, error) {
+func serializeStructInner(s interface{}, opts serializeOptions, parentAttrs attrs) ([]serializedFlag, error) {
 	// log.Printf("initializing struct for: %#v", s)
 
 	// We expect the incoming value to be a struct or a pointer to a struct.
 	// Anything else is invalid.
 	structVal := reflect.Indirect(reflect.ValueOf(s))
 	if structVal.Kind() != reflect.Struct {
-		return "", ErrConfigMustBeStruct
+		return nil, ErrConfigMustBeStruct
 	}
 
-	str := &strings.Builder{}
+	serialized := []serializedFlag{}
 
 	fields := reflect.VisibleFields(structVal.Type())
 	// log.Printf("fields: %#v", fields)
@@ -92,7 +298,31 @@ func serializeStructInner(s interface{}, omitEmpty bool, parentAttrs attrs) (str
 		// log.Printf("handling field %q : anonymous? %v, index: %v", canonicalName, f.Anonymous, f.Index)
 
 		fieldVal := structVal.FieldByIndex(f.Index)
+		fieldType := f.Type
+
+		// Optional (pointer) fields serialize as their pointed-to value, or
+		// as empty if nil.
+		isNil := false
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+
+			if fieldVal.IsNil() {
+				if opts.exact {
+					continue
+				}
+				fieldVal = reflect.Zero(fieldType)
+				isNil = true
+			} else {
+				fieldVal = fieldVal.Elem()
+			}
+		}
+
 		intf := fieldVal.Interface()
+		exact := opts.exact
+
+		// A custom type's flag might not parse an empty (or zero) value at
+		// all, in which case exact mode can't give it.
+		emptyOK := true
 
 		// switch it := intf.(type) {
 		// default:
@@ -105,12 +335,12 @@ func serializeStructInner(s interface{}, omitEmpty bool, parentAttrs attrs) (str
 		// low-level "kinds" only if we need to...
 		switch val := intf.(type) {
 		case time.Time:
-			if !val.IsZero() {
+			if !val.IsZero() || exact {
 				fieldStr = val.Format(decoders.TimeLayout)
 			}
 
 		case time.Duration:
-			if val != 0 {
+			if val != 0 || exact {
 				fieldStr = val.String()
 			}
 
@@ -124,20 +354,37 @@ func serializeStructInner(s interface{}, omitEmpty bool, parentAttrs attrs) (str
 
 		case bool:
 			fieldStr = fmt.Sprintf("%t", val)
-			if !val && omitEmpty {
+			if !val && opts.omitEmpty && !exact {
 				fieldStr = ""
 			}
 
 		case int:
-			if val != 0 {
+			if val != 0 || exact {
 				fieldStr = strconv.FormatInt(int64(val), 10)
 			}
 
 		case uint:
-			if val != 0 {
+			if val != 0 || exact {
 				fieldStr = strconv.FormatUint(uint64(val), 10)
 			}
 
+		// For the sized numbers, we lean on reflect to avoid an explosion of
+		// nearly-identical cases.
+		case int8, int16, int32, int64:
+			if i := fieldVal.Int(); i != 0 || exact {
+				fieldStr = strconv.FormatInt(i, 10)
+			}
+
+		case uint8, uint16, uint32, uint64:
+			if u := fieldVal.Uint(); u != 0 || exact {
+				fieldStr = strconv.FormatUint(u, 10)
+			}
+
+		case float32, float64:
+			if f := fieldVal.Float(); f != 0 || exact {
+				fieldStr = strconv.FormatFloat(f, 'g', -1, fieldType.Bits())
+			}
+
 		case string:
 			if val != "" {
 				fieldStr = val
@@ -167,14 +414,84 @@ func serializeStructInner(s interface{}, omitEmpty bool, parentAttrs attrs) (str
 				), ",")
 			}
 
+		case []int8, []int16, []int32, []int64:
+			fieldStr = joinSliceValue(fieldVal, func(v reflect.Value) string {
+				return strconv.FormatInt(v.Int(), 10)
+			})
+
+		case []uint16, []uint32, []uint64:
+			fieldStr = joinSliceValue(fieldVal, func(v reflect.Value) string {
+				return strconv.FormatUint(v.Uint(), 10)
+			})
+
+		case []float32, []float64:
+			bits := fieldType.Elem().Bits()
+			fieldStr = joinSliceValue(fieldVal, func(v reflect.Value) string {
+				return strconv.FormatFloat(v.Float(), 'g', -1, bits)
+			})
+
 		case []byte:
 			fieldStr = hex.EncodeToString(val)
 
 		case []string:
+			// pflag reads these as CSV, so values with commas (or quotes)
+			// need quoting.
 			if len(val) > 0 {
-				fieldStr = strings.Join(val, ",")
+				fieldStr = joinCSV(val)
+			}
+
+		case net.IP:
+			if len(val) > 0 {
+				fieldStr = val.String()
+			}
+
+		case []net.IP:
+			fieldStr = joinStringers(val)
+
+		case net.IPNet:
+			if val.IP != nil {
+				fieldStr = val.String()
+			}
+
+		case []net.IPNet:
+			fieldStr = strings.Join(mapSlice(
+				val,
+				func(n net.IPNet) string { return n.String() },
+			), ",")
+
+		case netip.Addr:
+			if val.IsValid() {
+				fieldStr = val.String()
 			}
 
+		case []netip.Addr:
+			fieldStr = joinStringers(val)
+
+		case netip.Prefix:
+			if val.IsValid() {
+				fieldStr = val.String()
+			}
+
+		case []netip.Prefix:
+			fieldStr = joinStringers(val)
+
+		case netip.AddrPort:
+			if val.IsValid() {
+				fieldStr = val.String()
+			}
+
+		case []netip.AddrPort:
+			fieldStr = joinStringers(val)
+
+		case url.URL:
+			fieldStr = val.String()
+
+		case []url.URL:
+			fieldStr = strings.Join(mapSlice(
+				val,
+				func(u url.URL) string { return u.String() },
+			), ",")
+
 		case map[string]int:
 			if len(val) > 0 {
 				fieldStr = strings.Join(mapMapToSlice(
@@ -185,32 +502,64 @@ func serializeStructInner(s interface{}, omitEmpty bool, parentAttrs attrs) (str
 
 		case map[string]string:
 			if len(val) > 0 {
-				fieldStr = strings.Join(mapMapToSlice(
+				fieldStr = joinCSV(mapMapToSlice(
 					val,
 					func(k string, v string) string { return fmt.Sprintf("%s=%s", k, v) },
-				), ",")
+				))
 			}
 
 		default:
-			if f.Type.Kind() == reflect.Struct {
+			// Just like processing, we check for self-describing types before
+			// looking at the kind.
+			ptr := reflect.New(fieldType)
+			ptr.Elem().Set(fieldVal)
+
+			if flagValue, ok := ptr.Interface().(pflag.Value); ok {
+				if !fieldVal.IsZero() {
+					fieldStr = flagValue.String()
+				} else if exact {
+					// We can't know whether a type's zero value can be given
+					// as a flag, so we have to try it.
+					fieldStr = flagValue.String()
+					emptyOK = reflect.New(fieldType).Interface().(pflag.Value).Set(fieldStr) == nil
+				}
+			} else if textValue, ok := ptr.Interface().(encoding.TextUnmarshaler); ok {
+				if !fieldVal.IsZero() {
+					fieldStr = decoders.NewTextValue(textValue).String()
+				} else if exact {
+					fieldStr = decoders.NewTextValue(textValue).String()
+					emptyOK = reflect.New(fieldType).Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(fieldStr)) == nil
+				}
+			} else if fieldType.Kind() == reflect.Struct {
 				recursiveAttrs := joinedAttrs
 
 				if f.Anonymous {
 					recursiveAttrs = parentAttrs
 				}
 
-				childStr, err := serializeStructInner(intf, omitEmpty, recursiveAttrs)
+				childSerialized, err := serializeStructInner(intf, opts, recursiveAttrs)
 				if err != nil {
-					return "", err
+					return nil, err
 				}
 
-				if str.Len() > 0 && len(childStr) > 0 {
-					str.WriteString(" ")
-				}
-				str.WriteString(childStr)
+				serialized = append(serialized, childSerialized...)
 
 				// unlike with processing, we need to skip the "end of switch"
 				// handling
+				continue
+			} else if isCollection(fieldType) {
+				// Collections are serialized as a repeated flag, one per item.
+				items, err := serializeCollection(fieldVal, joinedAttrs)
+				if err != nil {
+					return nil, err
+				}
+
+				if len(items) == 0 || isNil {
+					items = []serializedFlag{{long: joinedAttrs.long}}
+				}
+
+				serialized = append(serialized, items...)
+
 				continue
 			} else {
 				handled = false
@@ -219,23 +568,112 @@ func serializeStructInner(s interface{}, omitEmpty bool, parentAttrs attrs) (str
 
 		if !handled {
 			log.Printf("unsupported type? %q %#v", f.Type.Kind(), f)
-			return ",", ErrConfigFieldUnsupported
+			return nil, ErrConfigFieldUnsupported
 		}
 
-		if fieldStr != "" || !omitEmpty {
-			if str.Len() > 0 {
-				str.WriteString(" ")
-			}
-			formattedValue := fmt.Sprintf("%q", fieldStr)
-			if joinedAttrs.sensitive && fieldStr != "" {
-				formattedValue = "[REDACTED]"
+		if isNil {
+			fieldStr = ""
+		}
+
+		if exact && (fieldStr == "" || fieldVal.IsZero()) && !emptyOK {
+			return nil, fmt.Errorf("%w: --%s", ErrZeroValueNotSerializable, joinedAttrs.long)
+		}
+
+		serialized = append(serialized, serializedFlag{
+			long:      joinedAttrs.long,
+			env:       joinedAttrs.env,
+			value:     fieldStr,
+			sensitive: joinedAttrs.sensitive,
+		})
+	}
+
+	return serialized, nil
+}
+
+// serializeCollection serializes each item in a slice or map of structs as the
+// comma-separated "key=value" pairs that the collection flag expects.
+// Sensitive values are redacted individually, so that the rest of the item is
+// still useful for logging.  Each item also carries its individual fields,
+// with the indexed (or named) environment variables for them.
+func serializeCollection(v reflect.Value, collectionAttrs attrs) ([]serializedFlag, error) {
+	items := make([]serializedFlag, 0, v.Len())
+
+	if v.Kind() == reflect.Map {
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return cmp.Compare(a.String(), b.String()) })
+
+		for _, k := range keys {
+			item, err := serializeCollectionItem(v.MapIndex(k), collectionAttrs, k.String()+".", strings.ToUpper(k.String()))
+			if err != nil {
+				return nil, err
 			}
-			fmt.Fprintf(str, "--%s %s", joinedAttrs.long, formattedValue)
+			items = append(items, item)
 		}
 
+		return items, nil
 	}
 
-	return str.String(), nil
+	for i := 0; i < v.Len(); i++ {
+		item, err := serializeCollectionItem(v.Index(i), collectionAttrs, "", strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// serializeCollectionItem serializes a single item for [serializeCollection],
+// prefixing each key (and environment variable) as needed.
+func serializeCollectionItem(v reflect.Value, collectionAttrs attrs, prefix string, envPart string) (serializedFlag, error) {
+	item := serializedFlag{long: collectionAttrs.long}
+
+	serialized, err := serializeStructInner(v.Interface(), serializeOptions{omitEmpty: true}, attrs{
+		env:       joinField(collectionAttrs.env, envPart, "_"),
+		sensitive: collectionAttrs.sensitive,
+	})
+	if err != nil {
+		return item, err
+	}
+	item.leaves = serialized
+
+	pairs, redactedPairs := []string{}, []string{}
+	for _, flag := range serialized {
+		if flag.value == "" {
+			continue
+		}
+
+		pair := fmt.Sprintf("%s%s=%s", prefix, flag.long, flag.value)
+		pairs = append(pairs, pair)
+
+		if flag.sensitive {
+			pair = fmt.Sprintf("%s%s=[REDACTED]", prefix, flag.long)
+		}
+		redactedPairs = append(redactedPairs, pair)
+	}
+
+	// An entirely empty item still needs *something*, or it would look like
+	// the (empty) value that clears the collection.
+	if len(pairs) == 0 && len(serialized) > 0 {
+		pairs = append(pairs, fmt.Sprintf("%s%s=", prefix, serialized[0].long))
+		redactedPairs = pairs
+	}
+
+	item.value = joinCSV(pairs)
+	item.redacted = joinCSV(redactedPairs)
+	return item, nil
+}
+
+// joinCSV joins the values as a single CSV record, quoting them as needed.
+func joinCSV(values []string) string {
+	str := &strings.Builder{}
+	w := csv.NewWriter(str)
+	// (writing to a strings.Builder can't fail)
+	_ = w.Write(values)
+	w.Flush()
+
+	return strings.TrimSuffix(str.String(), "\n")
 }
 
 func mapSlice[S ~[]E, E any, X any](s S, fn func(E) X) []X {
@@ -248,6 +686,22 @@ func mapSlice[S ~[]E, E any, X any](s S, fn func(E) X) []X {
 	return x
 }
 
+// joinStringers formats and comma-joins the elements of a slice.
+func joinStringers[S ~[]E, E fmt.Stringer](s S) string {
+	return strings.Join(mapSlice(s, E.String), ",")
+}
+
+// joinSliceValue formats and comma-joins the elements of a slice value.
+func joinSliceValue(v reflect.Value, fn func(reflect.Value) string) string {
+	x := make([]string, 0, v.Len())
+
+	for i := 0; i < v.Len(); i++ {
+		x = append(x, fn(v.Index(i)))
+	}
+
+	return strings.Join(x, ",")
+}
+
 func mapMapToSlice[M map[K]V, K cmp.Ordered, V any, X any](m M, fn func(k K, v V) X) []X {
 	x := make([]X, 0, len(m))
 
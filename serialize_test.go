@@ -1,9 +1,17 @@
 package asp
 
 import (
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -92,7 +100,7 @@ func TestSerializeFlagsNonStruct(t *testing.T) {
 
 func TestSerializeFlagsUnsupportedType(t *testing.T) {
 	type unsupported struct {
-		Rune rune
+		Complex complex128
 	}
 
 	s, err := SerializeFlags(unsupported{}, false)
@@ -103,3 +111,545 @@ func TestSerializeFlagsUnsupportedType(t *testing.T) {
 	assert.ErrorIs(t, err, ErrConfigFieldUnsupported)
 	assert.Equal(t, "", s)
 }
+
+func TestSerializeFlagsOptional(t *testing.T) {
+	five := 5
+	cfg := processOptionalTestConfig{
+		Int:    &five,
+		Nested: &Nested{Dummy: 3},
+	}
+
+	s, err := SerializeFlags(cfg, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `--int "5" --nested-dummy "3"`, s)
+
+	s, err = SerializeFlags(cfg, false)
+	assert.NoError(t, err)
+	assert.Equal(t, `--int "5" --string "" --bool "" --duration "" --nested-dummy "3" --nil-nested-dummy ""`, s)
+}
+
+func TestSerializeFlagsSized(t *testing.T) {
+	cfg := processSizedTestConfig{
+		Int8:         -8,
+		Int64:        64,
+		Uint8:        8,
+		Uint64:       64,
+		Float32:      1.1,
+		Float64:      0.25,
+		Int16Slice:   []int16{-1, 2},
+		Uint32Slice:  []uint32{3, 4},
+		Float32Slice: []float32{1.1, 2.5},
+	}
+
+	s, err := SerializeFlags(cfg, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `--int-8 "-8" --int-64 "64" --uint-8 "8" --uint-64 "64" --float-32 "1.1" --float-64 "0.25" --int-16-slice "-1,2" --uint-32-slice "3,4" --float-32-slice "1.1,2.5"`, s)
+}
+
+func TestSerializeFlagsCustom(t *testing.T) {
+	region := regionCode("CA")
+	cfg := processCustomTestConfig{
+		Level:    slog.LevelDebug,
+		Region:   "US",
+		Upstream: hostPort{"localhost", 80},
+		Optional: &region,
+	}
+
+	s, err := SerializeFlags(cfg, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `--level "DEBUG" --region "US" --upstream "localhost:80" --optional "CA"`, s)
+
+	s, err = SerializeFlags(processCustomTestConfig{}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, ``, s)
+}
+
+func TestSerializeFlagsNetwork(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/8")
+	upstream, _ := url.Parse("http://upstream:8080")
+
+	cfg := processNetworkTestConfig{
+		IP:        net.ParseIP("10.0.0.1"),
+		IPs:       []net.IP{net.ParseIP("1.2.3.4"), net.ParseIP("::1")},
+		IPNet:     *ipNet,
+		IPNets:    []net.IPNet{*ipNet},
+		Addr:      netip.MustParseAddr("::1"),
+		Addrs:     []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		Prefix:    netip.MustParsePrefix("10.0.0.0/8"),
+		Prefixes:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")},
+		AddrPort:  netip.MustParseAddrPort("127.0.0.1:80"),
+		AddrPorts: []netip.AddrPort{netip.MustParseAddrPort("[::1]:443")},
+		URL:       url.URL{Scheme: "https", Host: "example.com"},
+		URLs:      []url.URL{{Scheme: "http", Host: "a"}, {Scheme: "http", Host: "b"}},
+		Upstream:  upstream,
+	}
+
+	s, err := SerializeFlags(cfg, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `--ip "10.0.0.1" --i-ps "1.2.3.4,::1" --ip-net "10.0.0.0/8" --ip-nets "10.0.0.0/8" --addr "::1" --addrs "1.2.3.4" --prefix "10.0.0.0/8" --prefixes "10.0.0.0/8,fd00::/8" --addr-port "127.0.0.1:80" --addr-ports "[::1]:443" --url "https://example.com" --ur-ls "http://a,http://b" --upstream "http://upstream:8080"`, s)
+
+	s, err = SerializeFlags(processNetworkTestConfig{}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, ``, s)
+}
+
+func TestSerializeFlagsCollection(t *testing.T) {
+	cfg := processCollectionTestConfig{
+		Upstreams: []upstream{
+			{Host: "one", Weight: 1, Token: "secret"},
+			{Host: "two,three", TLS: struct{ Enabled bool }{true}},
+		},
+	}
+
+	s, err := SerializeFlags(cfg, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `--upstreams "host=one,weight=1,token=[REDACTED]" --upstreams "\"host=two,three\",tls-enabled=true"`, s)
+
+	s, err = SerializeFlags(processCollectionTestConfig{}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, `--upstreams "" --nested-backends ""`, s)
+
+	// round-trip (without the sensitive value)
+	cfg.Upstreams[0].Token = ""
+	s, err = SerializeFlags(cfg, true)
+	assert.NoError(t, err)
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, processCollectionTestConfig{})
+	assert.NoError(t, err)
+
+	// the values are Go-quoted, so we can unquote them to get the args
+	args := []string{}
+	for _, m := range regexp.MustCompile(`(--\S+) ("(?:[^"\\]|\\.)*")`).FindAllStringSubmatch(s, -1) {
+		value, err := strconv.Unquote(m[2])
+		assert.NoError(t, err)
+		args = append(args, m[1], value)
+	}
+	err = cmd.ParseFlags(args)
+	assert.NoError(t, err)
+
+	actual, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, cfg.Upstreams, actual.Upstreams)
+}
+
+func TestSerializeFlagsKeyedCollection(t *testing.T) {
+	cfg := processKeyedTestConfig{
+		Databases: map[string]database{
+			"replica": {Host: "two", Password: "secret"},
+			"primary": {Host: "one", Port: 5432},
+			"empty":   {},
+		},
+	}
+
+	s, err := SerializeFlags(cfg, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `--databases "empty.host=" --databases "primary.host=one,primary.port=5432" --databases "replica.host=two,replica.password=[REDACTED]"`, s)
+
+	s, err = SerializeFlags(processKeyedTestConfig{}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, `--databases ""`, s)
+}
+
+func TestSerializeEnv(t *testing.T) {
+	cfg := processTestConfig{
+		Duration:    30 * time.Second,
+		Int:         -7,
+		String:      "something",
+		StringSlice: []string{"one", "two"},
+		Nested:      Nested{Dummy: 2},
+		AnonymousEmbedded: AnonymousEmbedded{
+			EmbeddedInt: 8,
+		},
+	}
+
+	env, err := SerializeEnv(cfg, "APP", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"APP_DURATION=30s",
+		"APP_INT=-7",
+		"APP_STRING=something",
+		"APP_STRINGSLICE=one,two",
+		"APP_NESTED_DUMMY=2",
+		"APP_EMBEDDEDINT=8",
+	}, env)
+
+	env, err = SerializeEnv(withSensitive{"public", "private", false, false}, "MY_", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"MY_PUBLIC=public", "MY_PRIVATE=[REDACTED]"}, env)
+
+	env, err = SerializeEnv(withSensitive{}, "APP", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"APP_PUBLIC=", "APP_PRIVATE="}, env)
+
+	_, err = SerializeEnv(1, "APP", false)
+	assert.ErrorIs(t, err, ErrConfigMustBeStruct)
+}
+
+func TestSerializeEnvCollection(t *testing.T) {
+	cfg := processCollectionTestConfig{
+		Upstreams: []upstream{
+			{Host: "a", Weight: 1},
+			{},
+			{Host: "c", Token: "secret"},
+		},
+	}
+	cfg.Nested.Backends = []Nested{{Dummy: 5}}
+
+	env, err := SerializeEnv(cfg, "APP", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"APP_UPSTREAMS_0_HOST=a",
+		"APP_UPSTREAMS_0_WEIGHT=1",
+		"APP_UPSTREAMS_2_HOST=c",
+		"APP_UPSTREAMS_2_TOKEN=[REDACTED]",
+		"APP_NESTED_BACKENDS_0_DUMMY=5",
+	}, env)
+
+	keyed := processKeyedTestConfig{
+		Databases: map[string]database{
+			"replica": {Host: "two", Password: "secret"},
+			"primary": {Host: "one", Port: 5432},
+		},
+	}
+
+	env, err = SerializeEnv(keyed, "APP", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"APP_DATABASES_PRIMARY_HOST=one",
+		"APP_DATABASES_PRIMARY_PORT=5432",
+		"APP_DATABASES_REPLICA_HOST=two",
+		"APP_DATABASES_REPLICA_PASSWORD=[REDACTED]",
+	}, env)
+}
+
+func TestSerializeEnvRoundTrip(t *testing.T) {
+	cfg := processCollectionTestConfig{
+		Upstreams: []upstream{{Host: "a", Weight: 1}, {Host: "b"}},
+	}
+	cfg.Upstreams[1].TLS.Enabled = true
+	cfg.Nested.Backends = []Nested{{Dummy: 5}}
+
+	env, err := SerializeEnv(cfg, "APP", true)
+	assert.NoError(t, err)
+
+	for _, kv := range env {
+		name, val, _ := strings.Cut(kv, "=")
+		t.Setenv(name, val)
+	}
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, processCollectionTestConfig{})
+	assert.NoError(t, err)
+
+	actual, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, cfg, *actual)
+}
+
+func TestSerializeDotEnv(t *testing.T) {
+	cfg := struct {
+		Plain   string
+		Special string
+		Secret  string `asp.sensitive:"true"`
+		Empty   string
+	}{
+		Plain:   "value",
+		Special: "a \"quoted\" $HOME\\path\nnext line",
+		Secret:  "shh",
+	}
+
+	s, err := SerializeDotEnv(cfg, "APP", true)
+	assert.NoError(t, err)
+	assert.Equal(t, `APP_PLAIN="value"
+APP_SPECIAL="a \"quoted\" \$HOME\\path\nnext line"
+APP_SECRET=[REDACTED]
+`, s)
+
+	s, err = SerializeDotEnv(cfg, "APP", false)
+	assert.NoError(t, err)
+	assert.Contains(t, s, "APP_EMPTY=\"\"\n")
+
+	_, err = SerializeDotEnv(1, "APP", false)
+	assert.ErrorIs(t, err, ErrConfigMustBeStruct)
+}
+
+// argsRoundTrip parses the serialized args with a command that has the given
+// defaults, and returns the resulting config.
+func argsRoundTrip[T Config](t *testing.T, cfg T, defaults T) T {
+	t.Helper()
+
+	args, err := SerializeArgs(cfg)
+	assert.NoError(t, err)
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, defaults)
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags(args)
+	assert.NoError(t, err, args)
+
+	actual, err := a.Config()
+	assert.NoError(t, err, args)
+	if actual == nil {
+		var zero T
+		return zero
+	}
+	return *actual
+}
+
+func TestSerializeArgs(t *testing.T) {
+	cfg := processTestConfig{
+		Time:            time.Date(2000, 1, 2, 3, 4, 5, 6, time.UTC),
+		Duration:        30 * time.Second,
+		DurationSlice:   []time.Duration{30 * time.Second, 5 * time.Minute},
+		Bool:            false,
+		Int:             -7,
+		String:          "with spaces, commas, and 'quotes'",
+		BoolSlice:       []bool{false, true},
+		IntSlice:        []int{1, -2, 3},
+		UintSlice:       []uint{4, 5},
+		ByteSlice:       []byte{0, 1, 0xff},
+		StringSlice:     []string{"one", "two, three", `"four"`},
+		MapStringInt:    map[string]int{"one": 1, "two": 2},
+		MapStringString: map[string]string{"key1": "value=1", "key2": "a,b"},
+		Nested:          Nested{Dummy: 2},
+	}
+
+	args, err := SerializeArgs(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"--time=2000-01-02T03:04:05.000000006Z",
+		"--duration=30s",
+		"--duration-slice=30s,5m0s",
+		"--bool=false",
+		"--int=-7",
+		"--uint=0",
+		"--string=with spaces, commas, and 'quotes'",
+		"--bool-slice=false,true",
+		"--int-slice=1,-2,3",
+		"--uint-slice=4,5",
+		"--byte-slice=0001ff",
+		`--string-slice=one,"two, three","""four"""`,
+		"--map-string-int=one=1,two=2",
+		`--map-string-string=key1=value=1,"key2=a,b"`,
+		"--nested-dummy=2",
+		"--embedded-int=0",
+	}, args)
+
+	assert.Equal(t, cfg, argsRoundTrip(t, cfg, processTestConfig{}))
+
+	// zero values must override non-zero defaults (although most empty lists
+	// and maps come back empty rather than nil)
+	defaults := processTestConfig{
+		Time:            time.Now().UTC(),
+		DurationSlice:   []time.Duration{time.Second},
+		Bool:            true,
+		Int:             5,
+		String:          "default",
+		BoolSlice:       []bool{true},
+		IntSlice:        []int{1},
+		UintSlice:       []uint{1},
+		ByteSlice:       []byte{1},
+		StringSlice:     []string{"default"},
+		MapStringInt:    map[string]int{"default": 1},
+		MapStringString: map[string]string{"default": "value"},
+		Nested:          Nested{Dummy: 9},
+	}
+	expected := processTestConfig{
+		BoolSlice:       []bool{},
+		IntSlice:        []int{},
+		UintSlice:       []uint{},
+		ByteSlice:       []byte{},
+		StringSlice:     []string{},
+		MapStringInt:    map[string]int{},
+		MapStringString: map[string]string{},
+	}
+	assert.Equal(t, expected, argsRoundTrip(t, processTestConfig{}, defaults))
+}
+
+func TestSerializeArgsSized(t *testing.T) {
+	cfg := processSizedTestConfig{
+		Int8:         -8,
+		Int16:        16,
+		Int32:        -32,
+		Int64:        64,
+		Uint8:        8,
+		Uint16:       16,
+		Uint32:       32,
+		Uint64:       64,
+		Float32:      1.1,
+		Float64:      0.25,
+		Int8Slice:    []int8{-1, 2},
+		Int16Slice:   []int16{-1, 2},
+		Int32Slice:   []int32{-1, 2},
+		Int64Slice:   []int64{-1, 2},
+		Uint16Slice:  []uint16{3, 4},
+		Uint32Slice:  []uint32{3, 4},
+		Uint64Slice:  []uint64{3, 4},
+		Float32Slice: []float32{1.1, 2.5},
+		Float64Slice: []float64{1.1, 2.5},
+	}
+	assert.Equal(t, cfg, argsRoundTrip(t, cfg, processSizedTestConfig{}))
+
+	defaults := processSizedTestConfig{
+		Int8:         1,
+		Uint64:       2,
+		Float32:      3,
+		Int8Slice:    []int8{1},
+		Int16Slice:   []int16{1},
+		Int32Slice:   []int32{1},
+		Int64Slice:   []int64{1},
+		Uint16Slice:  []uint16{1},
+		Uint32Slice:  []uint32{1},
+		Uint64Slice:  []uint64{1},
+		Float32Slice: []float32{1},
+		Float64Slice: []float64{1},
+	}
+	expected := processSizedTestConfig{
+		Int8Slice:    []int8{},
+		Int16Slice:   []int16{},
+		Int32Slice:   []int32{},
+		Int64Slice:   []int64{},
+		Uint16Slice:  []uint16{},
+		Uint32Slice:  []uint32{},
+		Uint64Slice:  []uint64{},
+		Float32Slice: []float32{},
+		Float64Slice: []float64{},
+	}
+	assert.Equal(t, expected, argsRoundTrip(t, processSizedTestConfig{}, defaults))
+}
+
+func TestSerializeArgsCustom(t *testing.T) {
+	region := regionCode("CA")
+	cfg := processCustomTestConfig{
+		Level:    slog.LevelDebug,
+		Region:   "US",
+		Upstream: hostPort{"localhost", 80},
+		Optional: &region,
+	}
+	assert.Equal(t, cfg, argsRoundTrip(t, cfg, processCustomTestConfig{}))
+
+	defaults := processCustomTestConfig{Level: slog.LevelWarn, Region: "CA", Upstream: hostPort{"default", 1}}
+	cfg = processCustomTestConfig{Region: "US"}
+	assert.Equal(t, cfg, argsRoundTrip(t, cfg, defaults))
+
+	// a zero value that the type itself can't parse can't be given exactly
+	_, err := SerializeArgs(processCustomTestConfig{})
+	assert.ErrorIs(t, err, ErrZeroValueNotSerializable)
+	assert.ErrorContains(t, err, "--region")
+}
+
+func TestSerializeArgsNetwork(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/8")
+	upstream, _ := url.Parse("http://upstream:8080/path?q=a%20b")
+
+	cfg := processNetworkTestConfig{
+		IP:        net.ParseIP("10.0.0.1"),
+		IPs:       []net.IP{net.ParseIP("1.2.3.4"), net.ParseIP("::1")},
+		IPNet:     *ipNet,
+		IPNets:    []net.IPNet{*ipNet},
+		Addr:      netip.MustParseAddr("::1"),
+		Addrs:     []netip.Addr{netip.MustParseAddr("1.2.3.4")},
+		Prefix:    netip.MustParsePrefix("10.0.0.0/8"),
+		Prefixes:  []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		AddrPort:  netip.MustParseAddrPort("127.0.0.1:80"),
+		AddrPorts: []netip.AddrPort{netip.MustParseAddrPort("[::1]:443")},
+		URL:       url.URL{Scheme: "https", Host: "example.com"},
+		URLs:      []url.URL{{Scheme: "http", Host: "a"}, {Scheme: "http", Host: "b"}},
+		Upstream:  upstream,
+	}
+	assert.Equal(t, cfg, argsRoundTrip(t, cfg, processNetworkTestConfig{}))
+
+	defaults := processNetworkTestConfig{
+		IP:       net.ParseIP("10.0.0.1"),
+		IPs:      []net.IP{net.ParseIP("1.2.3.4")},
+		IPNet:    *ipNet,
+		Addr:     netip.MustParseAddr("::1"),
+		Prefix:   netip.MustParsePrefix("10.0.0.0/8"),
+		AddrPort: netip.MustParseAddrPort("127.0.0.1:80"),
+		URL:      url.URL{Scheme: "https", Host: "example.com"},
+	}
+	expected := processNetworkTestConfig{
+		IPs:       []net.IP{},
+		IPNets:    []net.IPNet{},
+		Addrs:     []netip.Addr{},
+		Prefixes:  []netip.Prefix{},
+		AddrPorts: []netip.AddrPort{},
+		URLs:      []url.URL{},
+	}
+	assert.Equal(t, expected, argsRoundTrip(t, processNetworkTestConfig{}, defaults))
+
+	// every zero value is given explicitly
+	args, err := SerializeArgs(processNetworkTestConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"--ip=", "--i-ps=", "--ip-net=", "--ip-nets=", "--addr=", "--addrs=", "--prefix=", "--prefixes=",
+		"--addr-port=", "--addr-ports=", "--url=", "--ur-ls=",
+	}, args)
+}
+
+func TestSerializeArgsOptional(t *testing.T) {
+	zero, empty := 0, ""
+	cfg := processOptionalTestConfig{
+		Int:    &zero,
+		String: &empty,
+		Nested: &Nested{},
+	}
+
+	args, err := SerializeArgs(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--int=0", "--string=", "--nested-dummy=0"}, args)
+
+	assert.Equal(t, cfg, argsRoundTrip(t, cfg, processOptionalTestConfig{}))
+}
+
+func TestSerializeArgsCollection(t *testing.T) {
+	cfg := processCollectionTestConfig{
+		Upstreams: []upstream{
+			{Host: "one", Weight: 1, Token: "secret"},
+			{Host: "two,three"},
+		},
+	}
+	cfg.Upstreams[1].TLS.Enabled = true
+	cfg.Nested.Backends = []Nested{}
+
+	args, err := SerializeArgs(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"--upstreams=host=one,weight=1,token=secret",
+		`--upstreams="host=two,three",tls-enabled=true`,
+		"--nested-backends=",
+	}, args)
+	assert.Equal(t, cfg, argsRoundTrip(t, cfg, processCollectionTestConfig{}))
+
+	keyed := processKeyedTestConfig{
+		Databases: map[string]database{
+			"primary": {Host: "one", Port: 5432, Password: "pw"},
+		},
+	}
+	assert.Equal(t, keyed, argsRoundTrip(t, keyed, processKeyedTestConfig{}))
+
+	// an empty collection clears the default
+	defaults := processKeyedTestConfig{Databases: map[string]database{"default": {Host: "x"}}}
+	assert.Empty(t, argsRoundTrip(t, processKeyedTestConfig{}, defaults).Databases)
+}
+
+func TestSerializeShell(t *testing.T) {
+	cfg := withSensitive{Public: "it's got spaces", Private: "secret"}
+
+	s, err := SerializeShell(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, `'--public=it'\''s got spaces' --private=secret`, s)
+
+	_, err = SerializeShell(1)
+	assert.ErrorIs(t, err, ErrConfigMustBeStruct)
+}
+
+func TestQuoteShell(t *testing.T) {
+	assert.Equal(t, "--simple=value,1.2/3:4@5%6+7", quoteShell("--simple=value,1.2/3:4@5%6+7"))
+	assert.Equal(t, "''", quoteShell(""))
+	assert.Equal(t, `'$HOME'`, quoteShell("$HOME"))
+	assert.Equal(t, `'a'\''b'`, quoteShell("a'b"))
+	assert.Equal(t, "'line\nbreak'", quoteShell("line\nbreak"))
+}
@@ -0,0 +1,61 @@
+package asp
+
+import (
+	"os"
+	"strings"
+)
+
+// Setting describes the various names by which a single configuration value
+// can be provided.
+type Setting struct {
+	Field string // the Go field path, like "Database.Host"
+	Key   string // the config file key, like "database.host"
+	Flag  string // the long flag name (without the leading "--")
+	Env   string // the environment variable
+}
+
+// String renders the setting with all of its names, suitable for error
+// messages, like `Database.Host (--database-host, APP_DATABASE_HOST,
+// database.host)`.
+func (s Setting) String() string {
+	return s.Field + " (--" + s.Flag + ", " + s.Env + ", " + s.Key + ")"
+}
+
+// field is the registry entry for each leaf (non-struct) setting or collection
+// created by [aspBase.processStructInner], so that we can check on them after
+// the fact.
+type field struct {
+	attrs      attrs
+	collection *collection // only for slice- and map-of-struct fields
+	def        string      // the formatted default value...
+	defVal     any         // ... and the actual value...
+	hasDef     bool        // ... if there is one (nil pointers have none)
+}
+
+// setting returns the public description of the field.
+func (f *field) setting() Setting {
+	return Setting{
+		Field: f.attrs.name,
+		Key:   strings.ToLower(f.attrs.name),
+		Flag:  f.attrs.long,
+		Env:   f.attrs.env,
+	}
+}
+
+// isSupplied returns whether a flag, environment variable, or config file
+// provided a value for the field; defaults don't count.
+func (a *aspBase) isSupplied(f *field) bool {
+	if flag := a.cmd.PersistentFlags().Lookup(f.attrs.long); flag != nil && flag.Changed {
+		return true
+	}
+
+	if f.collection != nil {
+		if f.collection.fromEnv() != nil {
+			return true
+		}
+	} else if os.Getenv(f.attrs.env) != "" {
+		return true
+	}
+
+	return a.vip.InConfig(strings.ToLower(f.attrs.name))
+}
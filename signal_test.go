@@ -0,0 +1,87 @@
+//go:build unix
+
+package asp
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadOnSignal(t *testing.T) {
+	a, file := newWatchedInstance(t, "level: debug\nlimit: 1\n")
+
+	err := a.Reload()
+	assert.NoError(t, err)
+
+	changes := make(chan change, 10)
+	a.OnChange(func(old, new *watchTestConfig) {
+		changes <- change{old, new}
+	})
+
+	failures := make(chan error, 10)
+	a.OnReloadError(func(err error) {
+		failures <- err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a.ReloadOnSignal(ctx)
+
+	writeFile(t, file, "level: bogus\n")
+	err = syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+	assert.NoError(t, err)
+
+	select {
+	case err := <-failures:
+		assert.ErrorIs(t, err, ErrInvalid)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for failure")
+	}
+	assert.Equal(t, &watchTestConfig{Level: "debug", Limit: 1}, a.Current())
+
+	writeFile(t, file, "level: warn\nlimit: 2\n")
+	err = syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+	assert.NoError(t, err)
+
+	select {
+	case c := <-changes:
+		assert.Equal(t, &watchTestConfig{Level: "debug", Limit: 1}, c.old)
+		assert.Equal(t, &watchTestConfig{Level: "warn", Limit: 2}, c.new)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+	assert.Equal(t, &watchTestConfig{Level: "warn", Limit: 2}, a.Current())
+}
+
+func TestReloadOnSignalCustom(t *testing.T) {
+	a, file := newWatchedInstance(t, "limit: 1\n")
+
+	err := a.Reload()
+	assert.NoError(t, err)
+
+	changes := make(chan change, 10)
+	a.OnChange(func(old, new *watchTestConfig) {
+		changes <- change{old, new}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.ReloadOnSignal(ctx, syscall.SIGUSR1)
+
+	writeFile(t, file, "limit: 2\n")
+	err = syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	assert.NoError(t, err)
+
+	select {
+	case c := <-changes:
+		assert.Equal(t, 2, c.new.Limit)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+
+	cancel()
+}
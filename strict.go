@@ -0,0 +1,325 @@
+package asp
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownEnv is the sentinel (wrapped by [UnknownEnvError]) that indicates
+// there are environment variables with the app's prefix that don't match any
+// config field.
+var ErrUnknownEnv = errors.New("environment variables with the app prefix are unknown")
+
+// ErrUnknownKeys is the sentinel (wrapped by [UnknownKeysError]) that
+// indicates the config file has keys that don't match any config field.
+var ErrUnknownKeys = errors.New("config file has unknown keys")
+
+// UnknownKey is a config file key (or environment variable) that doesn't
+// match any config field, along with the closest valid key, if there's one
+// that's close enough.
+type UnknownKey struct {
+	Key        string // the full key, like "database.time_out"
+	Suggestion string // the closest valid full key, like "database.timeout"
+}
+
+// String renders the key with its suggestion (if any), like `database.time_out
+// (did you mean "database.timeout"?)`.
+func (k UnknownKey) String() string {
+	if k.Suggestion == "" {
+		return k.Key
+	}
+	return fmt.Sprintf("%s (did you mean %q?)", k.Key, k.Suggestion)
+}
+
+// UnknownKeysError is reported by [Asp.Config] when [WithStrictConfig] is
+// used and the config file has keys that don't match any config field.  It
+// lists *all* of the unknown keys, not just the first.
+type UnknownKeysError struct {
+	File    string
+	Unknown []UnknownKey
+}
+
+// Error lists each of the unknown keys.
+func (e *UnknownKeysError) Error() string {
+	unknown := mapSlice(e.Unknown, UnknownKey.String)
+	return ErrUnknownKeys.Error() + " (" + e.File + "): " + strings.Join(unknown, "; ")
+}
+
+// Unwrap allows [errors.Is] to match [ErrUnknownKeys].
+func (e *UnknownKeysError) Unwrap() error {
+	return ErrUnknownKeys
+}
+
+// UnknownEnvError is reported by [Asp.Config] when [WithStrictEnv] is used
+// and there are environment variables with the app's prefix that don't match
+// any config field.  It lists *all* of the unknown variables, not just the
+// first.
+type UnknownEnvError struct {
+	Unknown []UnknownKey
+}
+
+// Error lists each of the unknown environment variables.
+func (e *UnknownEnvError) Error() string {
+	unknown := mapSlice(e.Unknown, UnknownKey.String)
+	return ErrUnknownEnv.Error() + ": " + strings.Join(unknown, "; ")
+}
+
+// Unwrap allows [errors.Is] to match [ErrUnknownEnv].
+func (e *UnknownEnvError) Unwrap() error {
+	return ErrUnknownEnv
+}
+
+// checkStrictConfig reports an [UnknownKeysError] if strict config checking
+// is on and there are any unknown keys.  Since every flag, environment
+// variable, and default has a known key, any unknown keys have to have come
+// from the config file.
+func (a *aspBase) checkStrictConfig() error {
+	if !a.strictConfig {
+		return nil
+	}
+
+	unknown := unknownKeys(a.settings(), a.baseType, "")
+
+	// The selected profile has already been merged into the settings, but the
+	// other profiles need checking, too.  Since any top-level section that
+	// isn't a config field is a profile, one whose name is close to a config
+	// key is most likely a misspelled section instead.
+	selected := a.selectedProfile()
+	keys := slices.Sorted(maps.Keys(knownKeys(a.baseType)))
+	for _, name := range a.profileNames() {
+		if suggestion := suggest(name, keys); suggestion != "" {
+			unknown = append(unknown, UnknownKey{Key: name, Suggestion: suggestion})
+		} else if name != selected {
+			unknown = append(unknown, unknownKeys(a.profileSections[name], a.baseType, name+".")...)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	return a.warn(&UnknownKeysError{File: strings.Join(a.filesRead, ", "), Unknown: unknown})
+}
+
+// checkStrictEnv reports an [UnknownEnvError] if strict environment checking
+// is on and there are any (non-empty) environment variables with the app's
+// prefix that asp doesn't use.  Without a prefix, there's no way to tell the
+// app's variables apart, so nothing is checked.
+func (a *aspBase) checkStrictEnv() error {
+	prefix := strings.TrimRight(a.envPrefix, "_")
+	if !a.strictEnv || prefix == "" {
+		return nil
+	}
+
+	known := map[string]bool{}
+	for _, f := range a.fields {
+		if f.collection == nil {
+			known[f.attrs.env] = true
+		}
+	}
+	if a.profiles {
+		known[a.profileEnv()] = true
+	}
+
+	candidates := slices.Sorted(maps.Keys(known))
+	unknown := []UnknownKey{}
+
+	for _, kv := range os.Environ() {
+		name, val, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, prefix+"_") || val == "" || known[name] {
+			continue
+		}
+
+		envCandidates := slices.Clone(candidates)
+		matched := false
+		for _, c := range a.collections {
+			if c.matchesEnv(name) {
+				matched = true
+				break
+			}
+			envCandidates = append(envCandidates, c.envCandidates(name)...)
+		}
+		if matched {
+			continue
+		}
+
+		unknown = append(unknown, UnknownKey{Key: name, Suggestion: suggest(name, envCandidates)})
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(unknown, func(a, b UnknownKey) int { return strings.Compare(a.Key, b.Key) })
+	return a.warn(&UnknownEnvError{Unknown: unknown})
+}
+
+// warn passes the error to the warning handler, if there is one, or returns
+// it otherwise.
+func (a *aspBase) warn(err error) error {
+	if a.warningHandler == nil {
+		return err
+	}
+
+	a.warningHandler(err)
+	return nil
+}
+
+// unknownKeys walks the (nested) settings, reporting any keys that don't match
+// a field of the struct type; the logic is very similar to
+// [aspBase.processStructInner].
+func unknownKeys(m map[string]any, t reflect.Type, prefix string) []UnknownKey {
+	known := knownKeys(t)
+
+	candidates := make([]string, 0, len(known))
+	for key := range known {
+		candidates = append(candidates, key)
+	}
+	sort.Strings(candidates)
+
+	unknown := []UnknownKey{}
+
+	for _, key := range sortedKeys(m) {
+		fieldType, ok := known[strings.ToLower(key)]
+		if !ok {
+			u := UnknownKey{Key: prefix + key}
+			if suggestion := suggest(strings.ToLower(key), candidates); suggestion != "" {
+				u.Suggestion = prefix + suggestion
+			}
+			unknown = append(unknown, u)
+			continue
+		}
+
+		val := m[key]
+		switch {
+		case isNestedStruct(fieldType):
+			if sub, ok := val.(map[string]any); ok {
+				unknown = append(unknown, unknownKeys(sub, fieldType, prefix+key+".")...)
+			}
+
+		case isCollection(fieldType) && fieldType.Kind() == reflect.Map:
+			if sub, ok := val.(map[string]any); ok {
+				for _, name := range sortedKeys(sub) {
+					if item, ok := sub[name].(map[string]any); ok {
+						unknown = append(unknown, unknownKeys(item, fieldType.Elem(), prefix+key+"."+name+".")...)
+					}
+				}
+			}
+
+		case isCollection(fieldType):
+			items := reflect.ValueOf(val)
+			if items.Kind() != reflect.Slice {
+				continue
+			}
+			for i := 0; i < items.Len(); i++ {
+				if item, ok := items.Index(i).Interface().(map[string]any); ok {
+					unknown = append(unknown, unknownKeys(item, fieldType.Elem(), prefix+key+"."+strconv.Itoa(i)+".")...)
+				}
+			}
+		}
+	}
+
+	return unknown
+}
+
+// knownKeys returns the (lower-cased) keys for the fields of the struct type,
+// with embedded structs "inlined", and pointers dereferenced.
+func knownKeys(t reflect.Type) map[string]reflect.Type {
+	known := map[string]reflect.Type{}
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || len(f.Index) > 1 {
+			continue
+		}
+
+		childAttrs := getAttributes(f)
+		if childAttrs.ignored {
+			continue
+		}
+
+		fieldType := f.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if f.Anonymous && isNestedStruct(fieldType) {
+			for key, embeddedType := range knownKeys(fieldType) {
+				known[key] = embeddedType
+			}
+			continue
+		}
+
+		known[strings.ToLower(childAttrs.name)] = fieldType
+	}
+
+	return known
+}
+
+// sortedKeys returns the map's keys in order.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// suggest returns the candidate closest to s, if it's close enough to be a
+// likely typo: an edit distance of at most a third of the length of s (but at
+// least 1).
+func suggest(s string, candidates []string) string {
+	best := ""
+	bestDistance := max(1, len(s)/3) + 1
+
+	for _, c := range candidates {
+		d := editDistance(s, c)
+		if d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the "optimal string alignment" distance between a and
+// b: the Levenshtein distance, but also counting a transposition of adjacent
+// characters (like "prot" for "port") as a single edit.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// d[i][j] is the distance between the first i runes of a and the first j
+	// runes of b
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
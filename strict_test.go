@@ -0,0 +1,210 @@
+package asp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type strictTestConfig struct {
+	Timeout time.Duration
+	Port    int
+	Name    string `asp:"service-name"`
+	Ignored string `asp:"-"`
+	Labels  map[string]string
+	Nested  struct {
+		Enabled bool
+	}
+	AnonymousEmbedded
+	Upstreams []upstream
+	Databases map[string]database
+}
+
+func strictConfig(t *testing.T, name string, content string, options ...Option) (*strictTestConfig, error) {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(file, []byte(content), 0o600)
+	assert.NoError(t, err)
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, strictTestConfig{}, options...)
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", file})
+	assert.NoError(t, err)
+
+	return a.Config()
+}
+
+func TestStrictConfig(t *testing.T) {
+	content := `time_out: 5s
+port: 80
+name: svc
+ignored: x
+bogus: true
+labels:
+  anything: goes
+nested:
+  enabeld: true
+embeddedint: 1
+upstreams:
+  - host: one
+    wieght: 2
+    tls:
+      enabled: true
+databases:
+  primary:
+    host: db
+    passwrd: pw
+`
+
+	// not strict, so nothing is reported
+	cfg, err := strictConfig(t, "config.yaml", content)
+	assert.NoError(t, err)
+	assert.Equal(t, 80, cfg.Port)
+
+	_, err = strictConfig(t, "config.yaml", content, WithStrictConfig)
+	assert.ErrorIs(t, err, ErrUnknownKeys)
+
+	var unknownErr *UnknownKeysError
+	assert.True(t, errors.As(err, &unknownErr))
+	assert.Equal(t, "config.yaml", filepath.Base(unknownErr.File))
+	assert.Equal(t, []UnknownKey{
+		{Key: "bogus"},
+		{Key: "databases.primary.passwrd", Suggestion: "databases.primary.password"},
+		{Key: "ignored"},
+		{Key: "nested.enabeld", Suggestion: "nested.enabled"},
+		{Key: "time_out", Suggestion: "timeout"},
+		{Key: "upstreams.0.wieght", Suggestion: "upstreams.0.weight"},
+	}, unknownErr.Unknown)
+	assert.Contains(t, err.Error(), `time_out (did you mean "timeout"?); upstreams.0.wieght`)
+
+	// a clean config is fine
+	cfg, err = strictConfig(t, "config.yaml", "timeout: 5s\nupstreams:\n  - host: one\n", WithStrictConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.Timeout)
+}
+
+func TestStrictConfigTOML(t *testing.T) {
+	_, err := strictConfig(t, "config.toml", `prot = 80
+
+[[upstreams]]
+hots = "one"
+`, WithStrictConfig)
+
+	var unknownErr *UnknownKeysError
+	assert.True(t, errors.As(err, &unknownErr))
+	assert.Equal(t, []UnknownKey{
+		{Key: "prot", Suggestion: "port"},
+		{Key: "upstreams.0.hots", Suggestion: "upstreams.0.host"},
+	}, unknownErr.Unknown)
+}
+
+func TestStrictConfigWarning(t *testing.T) {
+	var warnings []error
+
+	cfg, err := strictConfig(t, "config.yaml", "port: 80\ntime_out: 5s\n",
+		WithStrictConfig,
+		WithWarningHandler(func(err error) {
+			warnings = append(warnings, err)
+		}))
+	assert.NoError(t, err)
+	assert.Equal(t, 80, cfg.Port)
+
+	assert.Len(t, warnings, 1)
+	assert.ErrorIs(t, warnings[0], ErrUnknownKeys)
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"host", "port", "timeout", "weight"}
+
+	assert.Equal(t, "timeout", suggest("time_out", candidates))
+	assert.Equal(t, "port", suggest("prot", candidates))
+	assert.Equal(t, "host", suggest("hsot", candidates))
+	assert.Equal(t, "weight", suggest("wieght", candidates))
+	assert.Equal(t, "", suggest("bogus", candidates))
+	assert.Equal(t, "", suggest("x", candidates))
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("abc", "abc"))
+	assert.Equal(t, 3, editDistance("", "abc"))
+	assert.Equal(t, 1, editDistance("abc", "abd"))
+	assert.Equal(t, 1, editDistance("abc", "acb"))
+	assert.Equal(t, 3, editDistance("kitten", "sitting"))
+	assert.Equal(t, 1, editDistance("héllo", "hello"))
+}
+
+func TestStrictEnv(t *testing.T) {
+	t.Setenv("APP_PORT", "80")
+	t.Setenv("APP_PROT", "81")
+	t.Setenv("APP_NESTED_ENABELD", "true")
+	t.Setenv("APP_UPSTREAMS_0_HOST", "one")
+	t.Setenv("APP_UPSTREAMS_0_HOTS", "two")
+	t.Setenv("APP_DATABASES_MY_DB_PASSWORD", "pw")
+	t.Setenv("APP_DATABASES_MY_DB_PASWORD", "pw")
+	t.Setenv("APP_EMPTY", "")
+	t.Setenv("OTHER_THING", "x")
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, strictTestConfig{})
+	assert.NoError(t, err)
+
+	// not strict, so nothing is reported
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, 80, cfg.Port)
+
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, strictTestConfig{}, WithStrictEnv)
+	assert.NoError(t, err)
+
+	_, err = a.Config()
+	assert.ErrorIs(t, err, ErrUnknownEnv)
+
+	var unknownErr *UnknownEnvError
+	assert.True(t, errors.As(err, &unknownErr))
+	assert.Equal(t, []UnknownKey{
+		{Key: "APP_DATABASES_MY_DB_PASWORD", Suggestion: "APP_DATABASES_MY_DB_PASSWORD"},
+		{Key: "APP_NESTED_ENABELD", Suggestion: "APP_NESTED_ENABLED"},
+		{Key: "APP_PROT", Suggestion: "APP_PORT"},
+		{Key: "APP_UPSTREAMS_0_HOTS", Suggestion: "APP_UPSTREAMS_0_HOST"},
+	}, unknownErr.Unknown)
+	assert.Contains(t, err.Error(), `APP_PROT (did you mean "APP_PORT"?)`)
+}
+
+func TestStrictEnvWarning(t *testing.T) {
+	t.Setenv("MY_PROT", "81")
+
+	var warnings []error
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, strictTestConfig{Port: 80},
+		WithEnvPrefix("MY_"),
+		WithStrictEnv,
+		WithWarningHandler(func(err error) {
+			warnings = append(warnings, err)
+		}))
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, 80, cfg.Port)
+
+	assert.Len(t, warnings, 1)
+	assert.ErrorIs(t, warnings[0], ErrUnknownEnv)
+
+	// without a prefix, nothing can be checked
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, strictTestConfig{}, WithEnvPrefix(""), WithStrictEnv)
+	assert.NoError(t, err)
+
+	_, err = a.Config()
+	assert.NoError(t, err)
+}
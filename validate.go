@@ -0,0 +1,317 @@
+package asp
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalid is the sentinel (wrapped by [ValidationError]) that indicates the
+// loaded configuration failed one or more constraints or Validate methods.
+var ErrInvalid = errors.New("configuration is invalid")
+
+// Validator can be implemented by the config struct, or any nested struct
+// within it, to provide custom validation; [Asp.Config] calls Validate after
+// loading the configuration.
+type Validator interface {
+	Validate() error
+}
+
+// InvalidSetting is a single validation failure, along with the setting it
+// applies to.  For a Validate method on a nested struct, the setting describes
+// the struct itself; for the top-level config struct, the setting is empty.
+type InvalidSetting struct {
+	Setting
+	Err error
+}
+
+// Error describes the setting and the failure.
+func (i InvalidSetting) Error() string {
+	if i.Field == "" {
+		return i.Err.Error()
+	}
+	return i.Setting.String() + ": " + i.Err.Error()
+}
+
+// Unwrap returns the underlying validation error.
+func (i InvalidSetting) Unwrap() error {
+	return i.Err
+}
+
+// ValidationError is returned by [Asp.Config] when the loaded configuration
+// fails any `asp.min`, `asp.max`, `asp.oneof`, or `asp.pattern` constraints, or
+// any [Validator]. It lists *all* of the failures, not just the first.
+type ValidationError struct {
+	Invalid []InvalidSetting
+}
+
+// Error lists each of the failures.
+func (e *ValidationError) Error() string {
+	invalid := mapSlice(e.Invalid, InvalidSetting.Error)
+	return ErrInvalid.Error() + ": " + strings.Join(invalid, "; ")
+}
+
+// Unwrap allows [errors.Is] to match [ErrInvalid].
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalid
+}
+
+// validate checks the constraints and calls the Validate methods for the
+// config struct (which must be a pointer) and all of its nested structs.
+func (a *aspBase) validate(cfg any) error {
+	invalid := []InvalidSetting{}
+	a.validateStruct(reflect.ValueOf(cfg), attrs{env: strings.TrimRight(a.envPrefix, "_")}, &invalid)
+
+	if len(invalid) > 0 {
+		return &ValidationError{Invalid: invalid}
+	}
+
+	return nil
+}
+
+// validateStruct is the (recursive) workhorse for [aspBase.validate]; the
+// field walking mirrors [aspBase.processStructInner].
+func (a *aspBase) validateStruct(v reflect.Value, structAttrs attrs, invalid *[]InvalidSetting) {
+	// We need an addressable value so that pointer-receiver Validate methods
+	// are found.
+	if v.Kind() != reflect.Pointer {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		v = ptr
+	}
+
+	if validator, ok := v.Interface().(Validator); ok {
+		err := validator.Validate()
+		if err != nil {
+			setting := Setting{}
+			if structAttrs.name != "" {
+				setting = (&field{attrs: structAttrs}).setting()
+			}
+			*invalid = append(*invalid, InvalidSetting{Setting: setting, Err: err})
+		}
+	}
+
+	structVal := v.Elem()
+
+	for _, f := range reflect.VisibleFields(structVal.Type()) {
+		if !f.IsExported() || len(f.Index) > 1 {
+			continue
+		}
+
+		childAttrs := getAttributes(f)
+		if childAttrs.ignored {
+			continue
+		}
+
+		joinedAttrs := structAttrs.join(childAttrs)
+
+		fieldVal := structVal.FieldByIndex(f.Index)
+		if fieldVal.Kind() == reflect.Pointer {
+			if fieldVal.IsNil() {
+				continue
+			}
+			fieldVal = fieldVal.Elem()
+		}
+		fieldType := fieldVal.Type()
+
+		switch {
+		case isNestedStruct(fieldType):
+			recursiveAttrs := joinedAttrs
+			if f.Anonymous {
+				recursiveAttrs = structAttrs
+			}
+			a.validateStruct(fieldVal, recursiveAttrs, invalid)
+
+		case isCollection(fieldType):
+			a.validateCollection(fieldVal, joinedAttrs, invalid)
+
+		default:
+			c, err := a.parseConstraints(joinedAttrs, fieldType)
+			if err == nil {
+				err = c.check(fieldVal)
+			}
+			if err != nil {
+				setting := (&field{attrs: joinedAttrs}).setting()
+				*invalid = append(*invalid, InvalidSetting{Setting: setting, Err: err})
+			}
+		}
+	}
+}
+
+// validateCollection validates each item in a slice or map of structs, naming
+// them like `Upstreams[0]` or `Databases[primary]`.
+func (a *aspBase) validateCollection(v reflect.Value, collectionAttrs attrs, invalid *[]InvalidSetting) {
+	itemAttrs := func(key any) attrs {
+		itemAttrs := collectionAttrs
+		itemAttrs.name = fmt.Sprintf("%s[%v]", collectionAttrs.name, key)
+		return itemAttrs
+	}
+
+	if v.Kind() == reflect.Map {
+		iter := v.MapRange()
+		for iter.Next() {
+			a.validateStruct(iter.Value(), itemAttrs(iter.Key()), invalid)
+		}
+		return
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		a.validateStruct(v.Index(i), itemAttrs(i), invalid)
+	}
+}
+
+// constraints are the parsed forms of the `asp.min`, `asp.max`, `asp.oneof`,
+// and `asp.pattern` tags for a field.
+type constraints struct {
+	min     *reflect.Value
+	max     *reflect.Value
+	length  bool // min and max apply to the length, not the value
+	oneOf   []reflect.Value
+	pattern *regexp.Regexp
+}
+
+// parseConstraints parses the constraint tags for a field of the given type.
+// The min, max, and oneof values are parsed by the decode hook (just like
+// `asp.default`), so that `asp.min:"1s"` works for a [time.Duration].  For
+// strings, slices, and maps, min and max are the length instead.
+func (a *aspBase) parseConstraints(at attrs, t reflect.Type) (*constraints, error) {
+	c := &constraints{}
+	var err error
+
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		c.length = true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		if at.min != "" || at.max != "" {
+			return nil, fmt.Errorf("asp.min and asp.max are not supported for %s", t)
+		}
+	}
+
+	parseBound := func(s string) (*reflect.Value, error) {
+		if s == "" {
+			return nil, nil
+		}
+
+		if c.length {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, err
+			}
+			v := reflect.ValueOf(n)
+			return &v, nil
+		}
+
+		v, err := a.decodeDefault(s, t)
+		if err != nil {
+			return nil, err
+		}
+		return &v, nil
+	}
+
+	c.min, err = parseBound(at.min)
+	if err != nil {
+		return nil, fmt.Errorf("asp.min %q: %w", at.min, err)
+	}
+
+	c.max, err = parseBound(at.max)
+	if err != nil {
+		return nil, fmt.Errorf("asp.max %q: %w", at.max, err)
+	}
+
+	// oneof and pattern apply to each element of a slice
+	elemType := t
+	if t.Kind() == reflect.Slice {
+		elemType = t.Elem()
+	}
+
+	if at.oneOf != "" {
+		for _, s := range strings.Split(at.oneOf, ",") {
+			v, err := a.decodeDefault(strings.TrimSpace(s), elemType)
+			if err != nil {
+				return nil, fmt.Errorf("asp.oneof %q: %w", at.oneOf, err)
+			}
+			c.oneOf = append(c.oneOf, v)
+		}
+	}
+
+	if at.pattern != "" {
+		if elemType.Kind() != reflect.String {
+			return nil, fmt.Errorf("asp.pattern is not supported for %s", t)
+		}
+
+		c.pattern, err = regexp.Compile(at.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("asp.pattern %q: %w", at.pattern, err)
+		}
+	}
+
+	return c, nil
+}
+
+// check returns an error describing the first constraint the value fails.
+func (c *constraints) check(v reflect.Value) error {
+	if c.length {
+		if c.min != nil && v.Len() < int(c.min.Int()) {
+			return fmt.Errorf("length must be at least %d", c.min.Int())
+		}
+		if c.max != nil && v.Len() > int(c.max.Int()) {
+			return fmt.Errorf("length must be at most %d", c.max.Int())
+		}
+	} else {
+		if c.min != nil && compareNumbers(v, *c.min) < 0 {
+			return fmt.Errorf("must be at least %v", c.min.Interface())
+		}
+		if c.max != nil && compareNumbers(v, *c.max) > 0 {
+			return fmt.Errorf("must be at most %v", c.max.Interface())
+		}
+	}
+
+	elems := []reflect.Value{v}
+	if v.Kind() == reflect.Slice {
+		elems = make([]reflect.Value, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, v.Index(i))
+		}
+	}
+
+	for _, e := range elems {
+		if len(c.oneOf) > 0 && !isOneOf(e, c.oneOf) {
+			options := mapSlice(c.oneOf, func(o reflect.Value) string { return fmt.Sprint(o.Interface()) })
+			return fmt.Errorf("%v must be one of: %s", e.Interface(), strings.Join(options, ", "))
+		}
+
+		if c.pattern != nil && !c.pattern.MatchString(e.String()) {
+			return fmt.Errorf("%q must match the pattern %q", e.String(), c.pattern.String())
+		}
+	}
+
+	return nil
+}
+
+// compareNumbers compares two values of the same numeric kind.
+func compareNumbers(a reflect.Value, b reflect.Value) int {
+	switch {
+	case a.CanInt():
+		return cmp.Compare(a.Int(), b.Int())
+	case a.CanUint():
+		return cmp.Compare(a.Uint(), b.Uint())
+	default:
+		return cmp.Compare(a.Float(), b.Float())
+	}
+}
+
+func isOneOf(v reflect.Value, options []reflect.Value) bool {
+	for _, o := range options {
+		if reflect.DeepEqual(v.Interface(), o.Interface()) {
+			return true
+		}
+	}
+	return false
+}
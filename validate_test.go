@@ -0,0 +1,150 @@
+package asp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstraints(t *testing.T) {
+	t.Parallel()
+
+	a := &aspBase{decodeHook: DefaultDecodeHook}
+
+	cases := map[string]struct {
+		attrs    attrs
+		value    any
+		expected string // empty for success
+	}{
+		"none":            {attrs{}, 5, ""},
+		"min ok":          {attrs{min: "1"}, 1, ""},
+		"min fail":        {attrs{min: "1"}, 0, "must be at least 1"},
+		"max ok":          {attrs{max: "10"}, uint8(10), ""},
+		"max fail":        {attrs{max: "10"}, uint8(11), "must be at most 10"},
+		"float fail":      {attrs{min: "0.5"}, 0.25, "must be at least 0.5"},
+		"duration ok":     {attrs{min: "1s", max: "1m"}, 30 * time.Second, ""},
+		"duration fail":   {attrs{min: "1s"}, time.Millisecond, "must be at least 1s"},
+		"length ok":       {attrs{min: "2"}, "ab", ""},
+		"length fail":     {attrs{min: "2"}, "a", "length must be at least 2"},
+		"slice fail":      {attrs{max: "1"}, []int{1, 2}, "length must be at most 1"},
+		"oneof ok":        {attrs{oneOf: "red, green"}, "green", ""},
+		"oneof fail":      {attrs{oneOf: "red, green"}, "blue", "blue must be one of: red, green"},
+		"oneof int fail":  {attrs{oneOf: "1,2"}, 3, "3 must be one of: 1, 2"},
+		"oneof slice":     {attrs{oneOf: "a,b"}, []string{"a", "c"}, "c must be one of: a, b"},
+		"pattern ok":      {attrs{pattern: `^\w+$`}, "abc", ""},
+		"pattern fail":    {attrs{pattern: `^\w+$`}, "a-c", `"a-c" must match the pattern "^\\w+$"`},
+		"pattern slice":   {attrs{pattern: `^a`}, []string{"ab", "b"}, `"b" must match the pattern "^a"`},
+		"bad min":         {attrs{min: "x"}, 1, `asp.min "x"`},
+		"bad max length":  {attrs{max: "x"}, "", `asp.max "x"`},
+		"bad oneof":       {attrs{oneOf: "x"}, 1, `asp.oneof "x"`},
+		"bad pattern":     {attrs{pattern: "("}, "", `asp.pattern "("`},
+		"pattern non-str": {attrs{pattern: "x"}, 1, "asp.pattern is not supported for int"},
+		"min non-number":  {attrs{min: "1"}, true, "asp.min and asp.max are not supported for bool"},
+	}
+
+	for k, v := range cases {
+		t.Run(k, func(t *testing.T) {
+			t.Parallel()
+
+			val := reflect.ValueOf(v.value)
+			c, err := a.parseConstraints(v.attrs, val.Type())
+			if err == nil {
+				err = c.check(val)
+			}
+
+			if v.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, v.expected)
+			}
+		})
+	}
+}
+
+type validatedInner struct {
+	Port int `asp.min:"1" asp.max:"65535"`
+}
+
+func (v validatedInner) Validate() error {
+	if v.Port == 666 {
+		return errors.New("no")
+	}
+	return nil
+}
+
+type validatedTestConfig struct {
+	Level    string `asp.oneof:"debug,info"`
+	Name     string `asp.pattern:"^[a-z]+$"`
+	Server   validatedInner
+	Optional *validatedInner
+	Backends []validatedInner
+}
+
+func (v *validatedTestConfig) Validate() error {
+	if v.Name == "root" {
+		return errors.New("name cannot be root")
+	}
+	return nil
+}
+
+func TestConfigValidation(t *testing.T) {
+	defaults := validatedTestConfig{
+		Level:  "info",
+		Name:   "app",
+		Server: validatedInner{Port: 80},
+	}
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, defaults)
+	assert.NoError(t, err)
+
+	cfg, err := a.Config()
+	assert.NoError(t, err)
+	assert.Equal(t, "info", cfg.Level)
+
+	// every failure is reported
+	err = cmd.ParseFlags([]string{
+		"--level", "trace",
+		"--server-port", "0",
+		"--backends", "port=666",
+		"--backends", "port=70000",
+	})
+	assert.NoError(t, err)
+	t.Setenv("APP_NAME", "root")
+
+	_, err = a.Config()
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	var valErr *ValidationError
+	if assert.ErrorAs(t, err, &valErr) {
+		assert.Equal(t, []string{
+			"name cannot be root",
+			"Level (--level, APP_LEVEL, level): trace must be one of: debug, info",
+			"Server.Port (--server-port, APP_SERVER_PORT, server.port): must be at least 1",
+			"Backends[0] (--backends, APP_BACKENDS, backends[0]): no",
+			"Backends[1].Port (--backends-port, APP_BACKENDS_PORT, backends[1].port): must be at most 65535",
+		}, mapSlice(valErr.Invalid, InvalidSetting.Error))
+	}
+
+	// a nil optional struct isn't validated, but a non-nil one is
+	t.Setenv("APP_NAME", "UPPER")
+	t.Setenv("APP_OPTIONAL_PORT", "666")
+	cmd = &cobra.Command{}
+	a, err = AttachInstance(cmd, defaults)
+	assert.NoError(t, err)
+
+	_, err = a.Config()
+	assert.ErrorContains(t, err, `Name (--name, APP_NAME, name): "UPPER" must match the pattern "^[a-z]+$"; Optional (--optional, APP_OPTIONAL, optional): no`)
+}
+
+func TestAttachWithBadConstraint(t *testing.T) {
+	cmd := &cobra.Command{}
+	_, err := AttachInstance(cmd, struct {
+		Enabled bool `asp.min:"1"`
+	}{})
+	assert.ErrorContains(t, err, "on Enabled: asp.min and asp.max are not supported for bool")
+}
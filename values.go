@@ -0,0 +1,279 @@
+package asp
+
+import (
+	"cmp"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/jaredreisinger/asp/decoders"
+)
+
+// nodeOptions control how [configNodes] converts a config struct.
+type nodeOptions struct {
+	omitEmpty bool
+	sensitive SensitiveMode
+	comments  bool
+}
+
+// nodeKind is the kind of a [configNode].
+type nodeKind int
+
+const (
+	leafNode  nodeKind = iota // a plain value
+	tableNode                 // a nested struct, or a map of structs
+	listNode                  // a slice of structs
+)
+
+// configNode is a single key in the nested form that a config file would
+// have.  Unlike a map, the nodes keep the field order of the struct, and the
+// (rendered) description of each field.
+type configNode struct {
+	key      string
+	desc     string
+	kind     nodeKind
+	value    any
+	children []*configNode
+	items    [][]*configNode
+}
+
+// configNodes converts a config struct (or nested struct) into the nested
+// form that a config file would have, using the same (lower-cased) keys that
+// viper does.  Leaf values are converted by [plainValue].  Nil pointers are
+// omitted, and sensitive values are handled as requested.
+func configNodes(v reflect.Value, opts nodeOptions, parentAttrs attrs) ([]*configNode, error) {
+	nodes := []*configNode{}
+
+	structVal := reflect.Indirect(v)
+
+	for _, f := range reflect.VisibleFields(structVal.Type()) {
+		if !f.IsExported() || len(f.Index) > 1 {
+			continue
+		}
+
+		childAttrs := getAttributes(f)
+		if childAttrs.ignored {
+			continue
+		}
+
+		joinedAttrs := parentAttrs.join(childAttrs)
+
+		fieldVal := structVal.FieldByIndex(f.Index)
+		if fieldVal.Kind() == reflect.Pointer {
+			if fieldVal.IsNil() {
+				continue
+			}
+			fieldVal = fieldVal.Elem()
+		}
+
+		node := &configNode{key: strings.ToLower(childAttrs.name)}
+
+		if opts.comments && !isNestedStruct(fieldVal.Type()) {
+			desc, err := describe(f.Name, joinedAttrs, parentAttrs)
+			if err != nil {
+				return nil, err
+			}
+			node.desc = desc
+		}
+
+		switch {
+		case isNestedStruct(fieldVal.Type()) && f.Anonymous:
+			// embedded structs are "inlined", just like their settings keys
+			children, err := configNodes(fieldVal, opts, parentAttrs)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, children...)
+			continue
+
+		case isNestedStruct(fieldVal.Type()):
+			children, err := configNodes(fieldVal, opts, joinedAttrs)
+			if err != nil {
+				return nil, err
+			}
+			if len(children) == 0 && opts.omitEmpty {
+				continue
+			}
+			node.kind = tableNode
+			node.children = children
+
+		case isCollection(fieldVal.Type()):
+			if fieldVal.Len() == 0 && opts.omitEmpty {
+				continue
+			}
+			err := collectionNode(node, fieldVal, opts, joinedAttrs)
+			if err != nil {
+				return nil, err
+			}
+
+		case joinedAttrs.sensitive && !fieldVal.IsZero() && opts.sensitive != SensitiveInclude:
+			if opts.sensitive == SensitiveOmit {
+				continue
+			}
+			node.value = "[REDACTED]"
+
+		case fieldVal.IsZero() && opts.omitEmpty:
+			continue
+
+		default:
+			node.value = plainValue(fieldVal, opts.sensitive == SensitiveRedact, joinedAttrs)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+// collectionNode fills in the node for a slice (or map) of structs, whose
+// items have no descriptions, so as not to repeat them.
+func collectionNode(node *configNode, v reflect.Value, opts nodeOptions, collectionAttrs attrs) error {
+	itemOpts := opts
+	itemOpts.comments = false
+	itemAttrs := attrs{sensitive: collectionAttrs.sensitive}
+
+	if v.Kind() == reflect.Map {
+		node.kind = tableNode
+
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return cmp.Compare(a.String(), b.String()) })
+
+		for _, k := range keys {
+			children, err := configNodes(v.MapIndex(k), itemOpts, itemAttrs)
+			if err != nil {
+				return err
+			}
+			node.children = append(node.children, &configNode{key: k.String(), kind: tableNode, children: children})
+		}
+
+		return nil
+	}
+
+	node.kind = listNode
+	for i := 0; i < v.Len(); i++ {
+		children, err := configNodes(v.Index(i), itemOpts, itemAttrs)
+		if err != nil {
+			return err
+		}
+		node.items = append(node.items, children)
+	}
+
+	return nil
+}
+
+// nodesMap converts nodes into nested maps and lists.
+func nodesMap(nodes []*configNode) map[string]any {
+	m := map[string]any{}
+
+	for _, n := range nodes {
+		switch n.kind {
+		case tableNode:
+			m[n.key] = nodesMap(n.children)
+		case listNode:
+			list := make([]any, 0, len(n.items))
+			for _, item := range n.items {
+				list = append(list, nodesMap(item))
+			}
+			m[n.key] = list
+		default:
+			m[n.key] = n.value
+		}
+	}
+
+	return m
+}
+
+// configMap converts a config struct (or nested struct) into the nested-map
+// form that a config file would have; see [configNodes].
+func configMap(v reflect.Value, redact bool, parentAttrs attrs) map[string]any {
+	opts := nodeOptions{sensitive: SensitiveInclude}
+	if redact {
+		opts.sensitive = SensitiveRedact
+	}
+
+	// without comments, there are no descriptions to fail to render
+	nodes, _ := configNodes(v, opts, parentAttrs)
+	return nodesMap(nodes)
+}
+
+// plainValue converts a value into a "plain" form (bools, numbers, strings,
+// and lists and maps of those) suitable for serializing into any config file
+// format, and that the default decode hooks can parse back.
+func plainValue(v reflect.Value, redact bool, valueAttrs attrs) any {
+	t := v.Type()
+
+	// special cases first...
+	switch val := v.Interface().(type) {
+	case time.Time:
+		if val.IsZero() {
+			return ""
+		}
+		return val.Format(decoders.TimeLayout)
+
+	case []byte:
+		return hex.EncodeToString(val)
+	}
+
+	// Plain built-in types need no conversion.
+	if t.PkgPath() == "" {
+		switch t.Kind() {
+		case reflect.Bool, reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return v.Interface()
+		}
+	}
+
+	// Named types get to use their own formatting, if they have any; we need
+	// an addressable copy to find pointer-receiver methods.
+	if t.PkgPath() != "" {
+		ptr := reflect.New(t)
+		ptr.Elem().Set(v)
+
+		switch val := ptr.Interface().(type) {
+		case encoding.TextMarshaler:
+			b, err := val.MarshalText()
+			if err == nil {
+				return string(b)
+			}
+		case pflag.Value:
+			return val.String()
+		case fmt.Stringer:
+			return val.String()
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		list := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			list = append(list, plainElem(v.Index(i), redact, valueAttrs))
+		}
+		return list
+
+	case reflect.Map:
+		m := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = plainElem(iter.Value(), redact, valueAttrs)
+		}
+		return m
+	}
+
+	return v.Interface()
+}
+
+// plainElem converts a slice or map element, which may be a struct.
+func plainElem(v reflect.Value, redact bool, elemAttrs attrs) any {
+	if isNestedStruct(v.Type()) {
+		return configMap(v, redact, attrs{sensitive: elemAttrs.sensitive})
+	}
+	return plainValue(v, redact, elemAttrs)
+}
@@ -0,0 +1,70 @@
+package asp
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigMap(t *testing.T) {
+	type inner struct {
+		Secret string `asp.sensitive:"true"`
+		Count  int
+	}
+
+	type Embedded struct {
+		Inlined string
+	}
+
+	one := 1
+
+	cfg := struct {
+		Embedded
+		Name     string
+		When     time.Time
+		Wait     time.Duration
+		IP       net.IP
+		Bytes    []byte
+		Ptr      *int
+		NilPtr   *int
+		Inner    inner
+		Items    []inner
+		ByName   map[string]inner
+		ignored  string
+		Excluded string `asp:"-"`
+	}{
+		Embedded: Embedded{Inlined: "in"},
+		Name:     "name",
+		Wait:     time.Minute,
+		IP:       net.IPv4(10, 0, 0, 1),
+		Bytes:    []byte{0xde, 0xad},
+		Ptr:      &one,
+		Inner:    inner{Secret: "shh", Count: 2},
+		Items:    []inner{{Secret: "a"}, {}},
+		ByName:   map[string]inner{"x": {Count: 3}},
+		ignored:  "ignored",
+	}
+
+	expected := map[string]any{
+		"inlined": "in",
+		"name":    "name",
+		"when":    "",
+		"wait":    "1m0s",
+		"ip":      "10.0.0.1",
+		"bytes":   "dead",
+		"ptr":     1,
+		"inner":   map[string]any{"secret": "[REDACTED]", "count": 2},
+		"items": []any{
+			map[string]any{"secret": "[REDACTED]", "count": 0},
+			map[string]any{"secret": "", "count": 0},
+		},
+		"byname": map[string]any{"x": map[string]any{"secret": "", "count": 3}},
+	}
+	assert.Equal(t, expected, configMap(reflect.ValueOf(cfg), true, attrs{}))
+
+	unredacted := configMap(reflect.ValueOf(&cfg), false, attrs{})
+	assert.Equal(t, map[string]any{"secret": "shh", "count": 2}, unredacted["inner"])
+}
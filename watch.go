@@ -0,0 +1,309 @@
+package asp
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// ErrNoConfigFile indicates that [Asp.Watch] was called, but there's no config
+// file to watch.
+var ErrNoConfigFile = errors.New("no config file is in use")
+
+// watchState is the (typed) state for watching and reloading the config.
+type watchState[T Config] struct {
+	// reloadMu serializes reloads, so that change notifications are delivered
+	// in order.
+	reloadMu sync.Mutex
+	current  atomic.Pointer[T]
+
+	callbacksMu   sync.Mutex
+	onChange      []func(old, new *T)
+	onReloadError []func(err error)
+}
+
+func (a *asp[T]) OnChange(fn func(old, new *T)) {
+	a.callbacksMu.Lock()
+	defer a.callbacksMu.Unlock()
+	a.onChange = append(a.onChange, fn)
+}
+
+func (a *asp[T]) OnReloadError(fn func(err error)) {
+	a.callbacksMu.Lock()
+	defer a.callbacksMu.Unlock()
+	a.onReloadError = append(a.onReloadError, fn)
+}
+
+func (a *asp[T]) Current() *T {
+	return a.current.Load()
+}
+
+func (a *asp[T]) Watch(ctx context.Context) error {
+	// The initial load's error is returned, so it isn't also reported to the
+	// reload error callbacks.
+	err := a.reload()
+	if err != nil {
+		return err
+	}
+
+	targets := a.watchTargets()
+	if len(targets.files) == 0 {
+		return ErrNoConfigFile
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	w := &configWatcher{Watcher: watcher, dirs: map[string]bool{}}
+	err = w.update(targets)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go a.watch(ctx, w)
+	return nil
+}
+
+func (a *asp[T]) ReloadOnSignal(ctx context.Context, sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				_ = a.Reload()
+			}
+		}
+	}()
+}
+
+// watch handles the file events until the context is done.
+func (a *asp[T]) watch(ctx context.Context, w *configWatcher) {
+	defer w.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+
+			if !w.changed(event) {
+				continue
+			}
+
+			_ = a.Reload()
+
+			// The reload may have changed which files are in use.
+			err := w.update(a.watchTargets())
+			if err != nil {
+				a.reloadFailed(err)
+			}
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			a.reloadFailed(err)
+		}
+	}
+}
+
+// watchTargets describes what [Asp.Watch] needs to watch.
+type watchTargets struct {
+	files   []string        // the config files in use (absolute paths)
+	dirs    []string        // the directories to watch
+	paths   map[string]bool // files whose creation or removal changes the config
+	dropIns map[string]bool // drop-in directories, whose fragments come and go
+}
+
+// watchTargets returns what to watch for the config files from the most recent
+// load.  Unless an explicit config file is in use, that includes every file
+// the default config search could find, since a new one could replace the
+// current one (or, with [WithLayeredConfig], be merged with it).  It also
+// includes the drop-in directories, even empty or missing ones, since a
+// fragment can be added at any time.
+func (a *aspBase) watchTargets() watchTargets {
+	a.vipMu.Lock()
+	defer a.vipMu.Unlock()
+
+	targets := watchTargets{paths: map[string]bool{}, dropIns: map[string]bool{}}
+	dirs := map[string]bool{}
+
+	// We have to watch the entire directory (just like viper does) to pick up
+	// renames and atomic saves.
+	for _, file := range a.filesRead {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		targets.files = append(targets.files, file)
+		dirs[filepath.Dir(file)] = true
+	}
+
+	if a.layered || !a.withConfigFlag || a.cfgFile == "" {
+		for _, path := range a.configSearch() {
+			path, err := filepath.Abs(os.ExpandEnv(path))
+			if err != nil {
+				continue
+			}
+			dirs[filepath.Dir(path)] = true
+			for _, ext := range viper.SupportedExts {
+				targets.paths[path+"."+ext] = true
+			}
+		}
+	}
+
+	for _, dir := range a.dropInDirsRead {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		dirs[dir] = true
+		targets.dropIns[dir] = true
+
+		// a drop-in directory that's created later needs to be watched, too
+		targets.paths[dir] = true
+	}
+
+	targets.dirs = slices.Sorted(maps.Keys(dirs))
+	return targets
+}
+
+// configWatcher is a file watcher that keeps track of what it's watching.
+type configWatcher struct {
+	*fsnotify.Watcher
+
+	dirs      map[string]bool   // the directories being watched
+	realFiles map[string]string // each config file, and the real path to it
+	paths     map[string]bool   // see watchTargets
+	dropIns   map[string]bool   // see watchTargets
+}
+
+// update starts watching any new directories (ignoring those that don't
+// exist), and replaces the files and paths being watched for.
+func (w *configWatcher) update(targets watchTargets) error {
+	for _, dir := range targets.dirs {
+		if w.dirs[dir] {
+			continue
+		}
+
+		err := w.Add(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		w.dirs[dir] = true
+	}
+
+	w.realFiles = map[string]string{}
+	for _, file := range targets.files {
+		w.realFiles[file], _ = filepath.EvalSymlinks(file)
+	}
+	w.paths = targets.paths
+	w.dropIns = targets.dropIns
+
+	return nil
+}
+
+// changed reports whether the event changes the config: a config file itself
+// being written or (re-)created, the real path to one changing (as when a
+// Kubernetes ConfigMap is replaced), a file appearing or disappearing at one
+// of the watched paths, or a fragment appearing or disappearing in a drop-in
+// directory.
+func (w *configWatcher) changed(event fsnotify.Event) bool {
+	name := filepath.Clean(event.Name)
+	changed := (w.paths[name] || (w.dropIns[filepath.Dir(name)] && isConfigFile(name))) &&
+		event.Has(fsnotify.Create|fsnotify.Remove|fsnotify.Rename)
+
+	for file, realFile := range w.realFiles {
+		currentFile, _ := filepath.EvalSymlinks(file)
+		if (name == file && event.Has(fsnotify.Write|fsnotify.Create)) ||
+			(currentFile != "" && currentFile != realFile) {
+			w.realFiles[file] = currentFile
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+func (a *asp[T]) Reload() error {
+	err := a.reload()
+	if err != nil {
+		a.reloadFailed(err)
+	}
+	return err
+}
+
+// reload loads the config and publishes it, like [Asp.Reload], but without
+// notifying the [Asp.OnReloadError] callbacks on failure.  It only replaces the
+// snapshot when the config actually changes, so that Current keeps returning
+// the same pointer otherwise.  The very first load doesn't count as a change.
+func (a *asp[T]) reload() error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	cfg, err := a.Config()
+	if err != nil {
+		return err
+	}
+
+	old := a.current.Load()
+	if old != nil && reflect.DeepEqual(old, cfg) {
+		return nil
+	}
+
+	a.current.Store(cfg)
+	if old == nil {
+		return nil
+	}
+
+	a.callbacksMu.Lock()
+	callbacks := append([]func(old, new *T){}, a.onChange...)
+	a.callbacksMu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(old, cfg)
+	}
+
+	return nil
+}
+
+// reloadFailed notifies the [Asp.OnReloadError] callbacks.
+func (a *asp[T]) reloadFailed(err error) {
+	a.callbacksMu.Lock()
+	callbacks := append([]func(err error){}, a.onReloadError...)
+	a.callbacksMu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(err)
+	}
+}
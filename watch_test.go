@@ -0,0 +1,355 @@
+package asp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type watchTestConfig struct {
+	Level string `asp.oneof:"debug,info,warn"`
+	Limit int
+}
+
+func newWatchedInstance(t *testing.T, content string) (Asp[watchTestConfig], string) {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte(content), 0o600)
+	assert.NoError(t, err)
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, watchTestConfig{Level: "info"})
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", file})
+	assert.NoError(t, err)
+
+	return a, file
+}
+
+// writeFile replaces the file content the way many editors do, by writing a
+// new file and renaming it over the old one.
+func writeFile(t *testing.T, file string, content string) {
+	t.Helper()
+
+	tmp := file + ".tmp"
+	err := os.WriteFile(tmp, []byte(content), 0o600)
+	assert.NoError(t, err)
+	err = os.Rename(tmp, file)
+	assert.NoError(t, err)
+}
+
+type change struct {
+	old, new *watchTestConfig
+}
+
+func TestWatch(t *testing.T) {
+	a, file := newWatchedInstance(t, "level: debug\nlimit: 1\n")
+
+	changes := make(chan change, 10)
+	a.OnChange(func(old, new *watchTestConfig) {
+		changes <- change{old, new}
+	})
+
+	failures := make(chan error, 10)
+	a.OnReloadError(func(err error) {
+		failures <- err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := a.Watch(ctx)
+	assert.NoError(t, err)
+
+	writeFile(t, file, "level: warn\nlimit: 2\n")
+
+	select {
+	case c := <-changes:
+		assert.Equal(t, &watchTestConfig{Level: "debug", Limit: 1}, c.old)
+		assert.Equal(t, &watchTestConfig{Level: "warn", Limit: 2}, c.new)
+	case err := <-failures:
+		t.Fatalf("unexpected reload failure: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+
+	// an invalid config is reported, and doesn't count as a change
+	writeFile(t, file, "level: bogus\nlimit: 3\n")
+
+	select {
+	case err := <-failures:
+		assert.ErrorIs(t, err, ErrInvalid)
+	case c := <-changes:
+		t.Fatalf("unexpected change: %+v", c.new)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for failure")
+	}
+
+	// ... and the next change is from the last good config
+	writeFile(t, file, "level: info\nlimit: 4\n")
+
+	for {
+		select {
+		case c := <-changes:
+			assert.Equal(t, &watchTestConfig{Level: "warn", Limit: 2}, c.old)
+			assert.Equal(t, &watchTestConfig{Level: "info", Limit: 4}, c.new)
+			return
+		case <-failures:
+			// a partially-written file could fail; keep waiting
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for change")
+		}
+	}
+}
+
+func TestWatchUnchanged(t *testing.T) {
+	a, file := newWatchedInstance(t, "level: debug\n")
+
+	changes := make(chan change, 10)
+	a.OnChange(func(old, new *watchTestConfig) {
+		changes <- change{old, new}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := a.Watch(ctx)
+	assert.NoError(t, err)
+
+	// the same values (even in a different form) aren't a change
+	writeFile(t, file, "# still debug\nlevel: debug\n")
+
+	select {
+	case c := <-changes:
+		t.Fatalf("unexpected change: %+v", c.new)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+// With layered config, a new config file at any of the search paths is merged
+// in (and one that's removed is dropped).
+func TestWatchLayeredConfig(t *testing.T) {
+	high := t.TempDir()
+	low := t.TempDir()
+	writeLayer(t, filepath.Join(low, "app.yaml"), "level: debug\nlimit: 1\n")
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, watchTestConfig{Level: "info"},
+		WithDefaultConfigName("app"), WithConfigPaths(high, low), WithLayeredConfig)
+	assert.NoError(t, err)
+
+	changes := make(chan change, 10)
+	a.OnChange(func(old, new *watchTestConfig) {
+		changes <- change{old, new}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = a.Watch(ctx)
+	assert.NoError(t, err)
+
+	highFile := filepath.Join(high, "app.yaml")
+	writeFile(t, highFile, "level: warn\n")
+
+	select {
+	case c := <-changes:
+		assert.Equal(t, &watchTestConfig{Level: "warn", Limit: 1}, c.new)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+
+	err = os.Remove(highFile)
+	assert.NoError(t, err)
+
+	select {
+	case c := <-changes:
+		assert.Equal(t, &watchTestConfig{Level: "debug", Limit: 1}, c.new)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+}
+
+// Fragments that are added to (or removed from) a drop-in directory are picked
+// up, even if the directory starts out empty, or doesn't exist at all.
+func TestWatchDropInDir(t *testing.T) {
+	dir := t.TempDir()
+	file := writeLayer(t, filepath.Join(dir, "config.yaml"), "level: debug\nlimit: 1\n")
+	confd := filepath.Join(dir, "conf.d")
+	err := os.Mkdir(confd, 0o700)
+	assert.NoError(t, err)
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, watchTestConfig{Level: "info"}, WithDropInDir("conf.d"))
+	assert.NoError(t, err)
+
+	err = cmd.ParseFlags([]string{"--config", file})
+	assert.NoError(t, err)
+
+	changes := make(chan change, 10)
+	a.OnChange(func(old, new *watchTestConfig) {
+		changes <- change{old, new}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = a.Watch(ctx)
+	assert.NoError(t, err)
+
+	expectChange := func(expected *watchTestConfig) {
+		t.Helper()
+		select {
+		case c := <-changes:
+			assert.Equal(t, expected, c.new)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for change")
+		}
+	}
+
+	fragment := filepath.Join(confd, "10-limit.yaml")
+	writeFile(t, fragment, "limit: 2\n")
+	expectChange(&watchTestConfig{Level: "debug", Limit: 2})
+
+	err = os.Remove(fragment)
+	assert.NoError(t, err)
+	expectChange(&watchTestConfig{Level: "debug", Limit: 1})
+
+	// the whole directory goes away, and then comes back
+	err = os.Remove(confd)
+	assert.NoError(t, err)
+
+	replacement := writeLayer(t, filepath.Join(t.TempDir(), "conf.d", "20-level.yaml"), "level: warn\n")
+	err = os.Rename(filepath.Dir(replacement), confd)
+	assert.NoError(t, err)
+	expectChange(&watchTestConfig{Level: "warn", Limit: 1})
+}
+
+// Without an explicit config file, removing the default config file restores
+// the defaults, and a new one (in any format) is picked up.
+func TestReloadRemovedConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	file := writeLayer(t, filepath.Join(dir, "app.yaml"), "level: debug\nlimit: 99\n")
+
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, watchTestConfig{Level: "info"},
+		WithDefaultConfigName("app"), WithConfigPaths(dir))
+	assert.NoError(t, err)
+
+	err = a.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, &watchTestConfig{Level: "debug", Limit: 99}, a.Current())
+
+	err = os.Remove(file)
+	assert.NoError(t, err)
+
+	err = a.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, &watchTestConfig{Level: "info"}, a.Current())
+	assert.Empty(t, a.ConfigFiles())
+
+	tomlFile := writeLayer(t, filepath.Join(dir, "app.toml"), "limit = 3\n")
+
+	err = a.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, &watchTestConfig{Level: "info", Limit: 3}, a.Current())
+	assert.Equal(t, []string{tomlFile}, a.ConfigFiles())
+}
+
+func TestWatchErrors(t *testing.T) {
+	// no config file
+	cmd := &cobra.Command{}
+	a, err := AttachInstance(cmd, watchTestConfig{Level: "info"})
+	assert.NoError(t, err)
+
+	err = a.Watch(context.Background())
+	assert.ErrorIs(t, err, ErrNoConfigFile)
+
+	// the initial load fails; that's returned, not reported as a reload error
+	a, _ = newWatchedInstance(t, "level: bogus\n")
+
+	var reported error
+	a.OnReloadError(func(err error) {
+		reported = err
+	})
+
+	err = a.Watch(context.Background())
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.NoError(t, reported)
+}
+
+func TestCurrentAndReload(t *testing.T) {
+	a, file := newWatchedInstance(t, "level: debug\nlimit: 1\n")
+
+	assert.Nil(t, a.Current())
+
+	changes := make(chan change, 10)
+	a.OnChange(func(old, new *watchTestConfig) {
+		changes <- change{old, new}
+	})
+
+	err := a.Reload()
+	assert.NoError(t, err)
+	first := a.Current()
+	assert.Equal(t, &watchTestConfig{Level: "debug", Limit: 1}, first)
+	assert.Empty(t, changes, "the first load isn't a change")
+
+	// an unchanged config keeps the same snapshot
+	err = a.Reload()
+	assert.NoError(t, err)
+	assert.Same(t, first, a.Current())
+	assert.Empty(t, changes)
+
+	// a failed reload keeps the previous snapshot
+	writeFile(t, file, "level: bogus\n")
+	err = a.Reload()
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.Same(t, first, a.Current())
+
+	writeFile(t, file, "level: warn\nlimit: 2\n")
+	err = a.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, &watchTestConfig{Level: "warn", Limit: 2}, a.Current())
+	assert.Equal(t, change{first, a.Current()}, <-changes)
+}
+
+// This is mostly useful with `go test -race`.
+func TestCurrentConcurrent(t *testing.T) {
+	a, file := newWatchedInstance(t, "limit: 0\n")
+
+	err := a.Reload()
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	for range 8 {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for range 100 {
+				cfg := a.Current()
+				assert.NotNil(t, cfg)
+				_, err := a.Config()
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	for i := 1; i <= 20; i++ {
+		writeFile(t, file, fmt.Sprintf("limit: %d\n", i))
+		err := a.Reload()
+		assert.NoError(t, err)
+	}
+
+	for range 8 {
+		<-done
+	}
+
+	assert.Equal(t, 20, a.Current().Limit)
+}